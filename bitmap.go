@@ -0,0 +1,165 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Bitmap 位图字段(如故障字、告警字)的解码结果
+type Bitmap struct {
+	Value  uint64         // 字段的原始值
+	Width  int            // 字段的位数
+	Flags  []any          // 置位的单比特标签, 按位从低到高排列
+	Fields map[any]uint64 // 多比特子字段的值
+}
+
+// Bit 返回第i位(从最低位0开始)是否置位
+func (b Bitmap) Bit(i int) bool {
+	return i >= 0 && i < 64 && b.Value>>i&1 == 1
+}
+
+// Bits 返回每一位是否置位, 下标为位序号
+func (b Bitmap) Bits() []bool {
+	bits := make([]bool, b.Width)
+	for i := range bits {
+		bits[i] = b.Bit(i)
+	}
+	return bits
+}
+
+// Has 返回标签对应的位是否置位
+func (b Bitmap) Has(label any) bool {
+	return slices.Contains(b.Flags, label)
+}
+
+// bitField 多比特子字段, 占用从 offset 开始的 width 位
+type bitField struct {
+	label  any
+	offset int
+	width  int
+}
+
+func (f bitField) mask() uint64 {
+	return (uint64(1)<<f.width - 1) << f.offset
+}
+
+// bitmapValue 将编解码器的结果转换为字段宽度内的无符号值
+func bitmapValue(raw any, width int) (uint64, error) {
+	var v uint64
+	switch n := raw.(type) {
+	case int:
+		v = uint64(n)
+	case uint64:
+		v = n
+	default:
+		return 0, fmt.Errorf("bitmap value is not an integer: %T", raw)
+	}
+	if width < 64 {
+		v &= uint64(1)<<width - 1
+	}
+	return v, nil
+}
+
+// explainBitmap 按位图配置解释原始值
+func (ec *ExplainConfig) explainBitmap(v uint64, width int) Bitmap {
+	b := Bitmap{Value: v, Width: width}
+	for i := range min(width, 64) {
+		if label, ok := ec.bitmap[i]; ok && v>>i&1 == 1 {
+			b.Flags = append(b.Flags, label)
+		}
+	}
+	if len(ec.bitFields) > 0 {
+		b.Fields = make(map[any]uint64, len(ec.bitFields))
+		for _, f := range ec.bitFields {
+			b.Fields[f.label] = v & f.mask() >> f.offset
+		}
+	}
+	return b
+}
+
+// unexplainBitmap 将标签集合转换回宽度为 width 位的原始值, 支持 []any、[]string、Bitmap 和整数.
+// Bitmap 中没有标签也不属于子字段的位取自 Value, 其余位由 Flags 和 Fields 决定
+func (ec *ExplainConfig) unexplainBitmap(data any, width int) (uint64, error) {
+	var (
+		labels []any
+		fields map[any]uint64
+		value  uint64
+	)
+	switch v := data.(type) {
+	case Bitmap:
+		labels, fields = v.Flags, v.Fields
+		value = v.Value & ec.unlabeledMask(width)
+	case []any:
+		labels = v
+	case []string:
+		for _, s := range v {
+			labels = append(labels, s)
+		}
+	case int:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("unsupported data type for bitmap encoding: %T", data)
+	}
+	for _, label := range labels {
+		bit, ok := ec.bitOf(label)
+		if !ok {
+			return 0, fmt.Errorf("unknown bitmap label %v", label)
+		}
+		value |= 1 << bit
+	}
+	for label, v := range fields {
+		i := slices.IndexFunc(ec.bitFields, func(f bitField) bool { return f.label == label })
+		if i < 0 {
+			return 0, fmt.Errorf("unknown bitmap field %v", label)
+		}
+		f := ec.bitFields[i]
+		if v > f.mask()>>f.offset {
+			return 0, fmt.Errorf("bitmap field %v value %d overflows %d bits: %w", label, v, f.width, ErrLength)
+		}
+		value |= v << f.offset
+	}
+	return value, nil
+}
+
+// unlabeledMask 返回字段宽度内既没有标签也不属于子字段的位
+func (ec *ExplainConfig) unlabeledMask(width int) uint64 {
+	mask := ^uint64(0)
+	if width < 64 {
+		mask = uint64(1)<<width - 1
+	}
+	for bit := range ec.bitmap {
+		if bit >= 0 && bit < 64 {
+			mask &^= 1 << bit
+		}
+	}
+	for _, f := range ec.bitFields {
+		mask &^= f.mask()
+	}
+	return mask
+}
+
+// bitOf 查找标签对应的位
+func (ec *ExplainConfig) bitOf(label any) (int, bool) {
+	for bit, l := range ec.bitmap {
+		if l == label {
+			return bit, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,97 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	// 故障字: bit0急停 bit1过温 bit15绝缘故障, bit4-6为枪状态
+	options := []CodecOption{
+		WithBin(), WithLength(2),
+		WithBitmap(map[int]any{0: "急停", 1: "过温", 15: "绝缘故障"}),
+		WithBitField("枪状态", 4, 3),
+	}
+	decoded, err := NewFieldCodecConfig("fault", options...).Decode([]byte{0x80, 0x51})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := decoded.Explained.(Bitmap)
+	if !ok {
+		t.Fatalf("want Bitmap, got %T", decoded.Explained)
+	}
+	if b.Value != 0x8051 || b.Width != 16 {
+		t.Fatalf("unexpected value %#x width %d", b.Value, b.Width)
+	}
+	if !slices.Equal(b.Flags, []any{"急停", "绝缘故障"}) {
+		t.Fatalf("unexpected flags %v", b.Flags)
+	}
+	if !b.Has("急停") || b.Has("过温") {
+		t.Fatalf("Has: unexpected result for %v", b.Flags)
+	}
+	if b.Fields["枪状态"] != 5 {
+		t.Fatalf("want gun state 5, got %d", b.Fields["枪状态"])
+	}
+	bits := b.Bits()
+	if len(bits) != 16 || !bits[0] || bits[1] || !bits[4] || !bits[6] || !bits[15] {
+		t.Fatalf("unexpected bits %v", bits)
+	}
+
+	encoder := NewFieldCodecConfig("fault", append([]CodecOption{WithEncode()}, options...)...)
+	// 解码结果原样编码
+	if encoded, err := encoder.Encode(b); err != nil || !bytes.Equal(encoded, []byte{0x80, 0x51}) {
+		t.Fatalf("encode Bitmap: got % X, %v", encoded, err)
+	}
+	if encoded, err := encoder.Encode([]string{"过温", "绝缘故障"}); err != nil || !bytes.Equal(encoded, []byte{0x80, 0x02}) {
+		t.Fatalf("encode labels: got % X, %v", encoded, err)
+	}
+	if _, err := encoder.Encode([]any{"未知"}); err == nil {
+		t.Fatal("want error for unknown label")
+	}
+	if _, err := encoder.Encode(Bitmap{Fields: map[any]uint64{"枪状态": 8}}); !errors.Is(err, ErrLength) {
+		t.Fatalf("field overflow: want ErrLength, got %v", err)
+	}
+
+	// 没有标签的位(bit3)原样保留, 有标签的位和子字段以 Flags、Fields 为准
+	unlabeled, err := NewFieldCodecConfig("fault", options...).Decode([]byte{0x80, 0x59})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded, err := encoder.Encode(unlabeled.Explained); err != nil || !bytes.Equal(encoded, []byte{0x80, 0x59}) {
+		t.Fatalf("encode unlabeled bits: got % X, %v", encoded, err)
+	}
+	changed := Bitmap{Value: 0xFFFF_8059, Flags: []any{"绝缘故障"}, Fields: map[any]uint64{"枪状态": 2}}
+	if encoded, err := encoder.Encode(changed); err != nil || !bytes.Equal(encoded, []byte{0x80, 0x28}) {
+		t.Fatalf("encode changed bitmap: got % X, %v", encoded, err)
+	}
+}
+
+func TestWithBitField_Range(t *testing.T) {
+	for _, r := range [][2]int{{0, 0}, {-1, 2}, {60, 5}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("offset %d width %d: want panic", r[0], r[1])
+				}
+			}()
+			WithBitField("x", r[0], r[1])
+		}()
+	}
+	WithBitField("x", 0, 64)
+}
@@ -0,0 +1,252 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charset 字符串字段的字符集
+type Charset int
+
+const (
+	CharsetASCII   Charset = iota // ASCII, 解码时原样保留非ASCII字节, 编码时拒绝非ASCII字符
+	CharsetUTF8                   // UTF-8
+	CharsetGBK                    // GBK, 单字节ASCII和双字节汉字, 双字节部分与GB18030相同
+	CharsetGB18030                // GB18030, 在GBK的基础上支持四字节序列, 可以表示全部Unicode字符
+	CharsetUTF16LE                // UTF-16 小端序
+	CharsetUTF16BE                // UTF-16 大端序
+)
+
+func (c Charset) String() string {
+	switch c {
+	case CharsetASCII:
+		return "ASCII"
+	case CharsetUTF8:
+		return "UTF-8"
+	case CharsetGBK:
+		return "GBK"
+	case CharsetGB18030:
+		return "GB18030"
+	case CharsetUTF16LE:
+		return "UTF-16LE"
+	case CharsetUTF16BE:
+		return "UTF-16BE"
+	default:
+		return fmt.Sprintf("Charset(%d)", int(c))
+	}
+}
+
+// gb18030Table GB18030双字节区的映射表, 首字节0x81-0xFE、尾字节0x40-0xFE,
+// 每项为大端序的两字节Unicode码点, 0表示未定义.
+// 由 tools/gen_gb18030.py 使用Python标准库的gb18030编解码器生成, 共48132字节,
+// SHA-256为 2ee3cecb0aa8a9e312cd2384c9b92f7fbd4646a337ed4e0811feef3e794569bb
+//
+//go:generate python3 tools/gen_gb18030.py charset_gb18030.bin
+//go:embed charset_gb18030.bin
+var gb18030Table string
+
+const (
+	gbTrailCount = 0xFE - 0x40 + 1
+	// gbFourByteBMPEnd 四字节区中映射到基本多文种平面的序号上限
+	gbFourByteBMPEnd = 39420
+	// gbFourByteSupplementary 四字节区中映射到U+10000的序号(0x90308130)
+	gbFourByteSupplementary = 189000
+)
+
+// gb18030Ranges 四字节区映射到基本多文种平面的区间: 序号和码点同时递增,
+// 每个区间一直延续到下一个区间的起点
+var gb18030Ranges = [...][2]uint16{
+	{0x0000, 0x0080}, {0x0024, 0x00A5}, {0x0026, 0x00A9}, {0x002D, 0x00B2},
+	{0x0032, 0x00B8}, {0x0051, 0x00D8}, {0x0059, 0x00E2}, {0x005F, 0x00EB},
+	{0x0060, 0x00EE}, {0x0064, 0x00F4}, {0x0067, 0x00F8}, {0x0068, 0x00FB},
+	{0x0069, 0x00FD}, {0x006D, 0x0102}, {0x007E, 0x0114}, {0x0085, 0x011C},
+	{0x0094, 0x012C}, {0x00AC, 0x0145}, {0x00AF, 0x0149}, {0x00B3, 0x014E},
+	{0x00D0, 0x016C}, {0x0132, 0x01CF}, {0x0133, 0x01D1}, {0x0134, 0x01D3},
+	{0x0135, 0x01D5}, {0x0136, 0x01D7}, {0x0137, 0x01D9}, {0x0138, 0x01DB},
+	{0x0139, 0x01DD}, {0x0155, 0x01FA}, {0x01AC, 0x0252}, {0x01BB, 0x0262},
+	{0x0220, 0x02C8}, {0x0221, 0x02CC}, {0x022E, 0x02DA}, {0x02E5, 0x03A2},
+	{0x02E6, 0x03AA}, {0x02ED, 0x03C2}, {0x02EE, 0x03CA}, {0x0325, 0x0402},
+	{0x0333, 0x0450}, {0x0334, 0x0452}, {0x1EF2, 0x2011}, {0x1EF4, 0x2017},
+	{0x1EF5, 0x201A}, {0x1EF7, 0x201E}, {0x1EFE, 0x2027}, {0x1F07, 0x2031},
+	{0x1F08, 0x2034}, {0x1F09, 0x2036}, {0x1F0E, 0x203C}, {0x1F7E, 0x20AD},
+	{0x1FD4, 0x2104}, {0x1FD5, 0x2106}, {0x1FD8, 0x210A}, {0x1FE4, 0x2117},
+	{0x1FEE, 0x2122}, {0x202C, 0x216C}, {0x2030, 0x217A}, {0x2046, 0x2194},
+	{0x2048, 0x219A}, {0x20B6, 0x2209}, {0x20BC, 0x2210}, {0x20BD, 0x2212},
+	{0x20C0, 0x2216}, {0x20C4, 0x221B}, {0x20C6, 0x2221}, {0x20C8, 0x2224},
+	{0x20C9, 0x2226}, {0x20CA, 0x222C}, {0x20CC, 0x222F}, {0x20D1, 0x2238},
+	{0x20D6, 0x223E}, {0x20E0, 0x2249}, {0x20E3, 0x224D}, {0x20E8, 0x2253},
+	{0x20F5, 0x2262}, {0x20F7, 0x2268}, {0x20FD, 0x2270}, {0x2122, 0x2296},
+	{0x2125, 0x229A}, {0x2130, 0x22A6}, {0x2149, 0x22C0}, {0x219B, 0x2313},
+	{0x22E8, 0x246A}, {0x22F2, 0x249C}, {0x2356, 0x254C}, {0x235A, 0x2574},
+	{0x2367, 0x2590}, {0x236A, 0x2596}, {0x2374, 0x25A2}, {0x2384, 0x25B4},
+	{0x238C, 0x25BE}, {0x2394, 0x25C8}, {0x2397, 0x25CC}, {0x2399, 0x25D0},
+	{0x23AB, 0x25E6}, {0x23CA, 0x2607}, {0x23CC, 0x260A}, {0x2402, 0x2641},
+	{0x2403, 0x2643}, {0x2C41, 0x2E82}, {0x2C43, 0x2E85}, {0x2C46, 0x2E89},
+	{0x2C48, 0x2E8D}, {0x2C52, 0x2E98}, {0x2C61, 0x2EA8}, {0x2C63, 0x2EAB},
+	{0x2C66, 0x2EAF}, {0x2C6A, 0x2EB4}, {0x2C6C, 0x2EB8}, {0x2C6F, 0x2EBC},
+	{0x2C7D, 0x2ECB}, {0x2DA2, 0x2FFC}, {0x2DA6, 0x3004}, {0x2DA7, 0x3018},
+	{0x2DAC, 0x301F}, {0x2DAE, 0x302A}, {0x2DC2, 0x303F}, {0x2DC4, 0x3094},
+	{0x2DCB, 0x309F}, {0x2DCD, 0x30F7}, {0x2DD2, 0x30FF}, {0x2DD8, 0x312A},
+	{0x2ECE, 0x322A}, {0x2ED5, 0x3232}, {0x2F46, 0x32A4}, {0x3030, 0x3390},
+	{0x303C, 0x339F}, {0x303E, 0x33A2}, {0x3060, 0x33C5}, {0x3069, 0x33CF},
+	{0x306B, 0x33D3}, {0x306D, 0x33D6}, {0x30DE, 0x3448}, {0x3109, 0x3474},
+	{0x3233, 0x359F}, {0x32A2, 0x360F}, {0x32AD, 0x361B}, {0x35AA, 0x3919},
+	{0x35FF, 0x396F}, {0x365F, 0x39D1}, {0x366D, 0x39E0}, {0x3700, 0x3A74},
+	{0x37DA, 0x3B4F}, {0x38F9, 0x3C6F}, {0x396A, 0x3CE1}, {0x3CDF, 0x4057},
+	{0x3DE7, 0x4160}, {0x3FBE, 0x4338}, {0x4032, 0x43AD}, {0x4036, 0x43B2},
+	{0x4061, 0x43DE}, {0x4159, 0x44D7}, {0x42CE, 0x464D}, {0x42E2, 0x4662},
+	{0x43A3, 0x4724}, {0x43A8, 0x472A}, {0x43FA, 0x477D}, {0x440A, 0x478E},
+	{0x45C3, 0x4948}, {0x45F5, 0x497B}, {0x45F7, 0x497E}, {0x45FB, 0x4984},
+	{0x45FC, 0x4987}, {0x4610, 0x499C}, {0x4613, 0x49A0}, {0x4629, 0x49B8},
+	{0x48E8, 0x4C78}, {0x490F, 0x4CA4}, {0x497E, 0x4D1A}, {0x4A12, 0x4DAF},
+	{0x4A63, 0x9FA6}, {0x82BD, 0xE76C}, {0x82BE, 0xE7C8}, {0x82BF, 0xE7E7},
+	{0x82CC, 0xE815}, {0x82CD, 0xE819}, {0x82D2, 0xE81F}, {0x82D9, 0xE827},
+	{0x82DD, 0xE82D}, {0x82E1, 0xE833}, {0x82E9, 0xE83C}, {0x82F0, 0xE844},
+	{0x8300, 0xE856}, {0x830E, 0xE865}, {0x93D5, 0xF92D}, {0x9421, 0xF97A},
+	{0x943C, 0xF996}, {0x948D, 0xF9E8}, {0x9496, 0xF9F2}, {0x94B0, 0xFA10},
+	{0x94B1, 0xFA12}, {0x94B2, 0xFA15}, {0x94B5, 0xFA19}, {0x94BB, 0xFA22},
+	{0x94BC, 0xFA25}, {0x94BE, 0xFA2A}, {0x98C4, 0xFE32}, {0x98C5, 0xFE45},
+	{0x98C9, 0xFE53}, {0x98CA, 0xFE58}, {0x98CB, 0xFE67}, {0x98CC, 0xFE6C},
+	{0x9961, 0xFF5F}, {0x99E2, 0xFFE6},
+}
+
+// gbDecode2 查询双字节序列对应的字符
+func gbDecode2(lead, trail byte) rune {
+	if lead < 0x81 || lead > 0xFE || trail < 0x40 || trail > 0xFE || trail == 0x7F {
+		return utf8.RuneError
+	}
+	off := (int(lead-0x81)*gbTrailCount + int(trail-0x40)) * 2
+	r := rune(gb18030Table[off])<<8 | rune(gb18030Table[off+1])
+	if r == 0 {
+		return utf8.RuneError
+	}
+	return r
+}
+
+// gbEncodeTable 字符到双字节序列的反向映射, 首次编码时生成
+var gbEncodeTable = sync.OnceValue(func() []uint16 {
+	table := make([]uint16, 0x10000)
+	for i := 0; i < len(gb18030Table); i += 2 {
+		r := uint16(gb18030Table[i])<<8 | uint16(gb18030Table[i+1])
+		if r != 0 {
+			n := i / 2
+			table[r] = uint16(0x81+n/gbTrailCount)<<8 | uint16(0x40+n%gbTrailCount)
+		}
+	}
+	return table
+})
+
+// decodeGB 按GBK或GB18030解码, 无法识别的字节解码为U+FFFD
+func decodeGB(data []byte, fourByte bool) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			sb.WriteByte(b)
+			i++
+		case fourByte && i+3 < len(data) && data[i+1] >= 0x30 && data[i+1] <= 0x39:
+			sb.WriteRune(gbDecode4(data[i : i+4]))
+			i += 4
+		case i+1 < len(data):
+			sb.WriteRune(gbDecode2(b, data[i+1]))
+			i += 2
+		default:
+			sb.WriteRune(utf8.RuneError)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// gbDecode4 解码GB18030四字节序列
+func gbDecode4(b []byte) rune {
+	if b[0] < 0x81 || b[0] > 0xFE || b[2] < 0x81 || b[2] > 0xFE || b[3] < 0x30 || b[3] > 0x39 {
+		return utf8.RuneError
+	}
+	idx := ((int(b[0]-0x81)*10+int(b[1]-0x30))*126+int(b[2]-0x81))*10 + int(b[3]-0x30)
+	switch {
+	case idx < gbFourByteBMPEnd:
+		i := sort.Search(len(gb18030Ranges), func(i int) bool { return int(gb18030Ranges[i][0]) > idx }) - 1
+		return rune(gb18030Ranges[i][1]) + rune(idx-int(gb18030Ranges[i][0]))
+	case idx >= gbFourByteSupplementary && idx-gbFourByteSupplementary <= utf8.MaxRune-0x10000:
+		return rune(0x10000 + idx - gbFourByteSupplementary)
+	default:
+		return utf8.RuneError
+	}
+}
+
+// encodeGB 按GBK或GB18030编码, 字符集中没有的字符返回错误
+func encodeGB(s string, fourByte bool) ([]byte, error) {
+	table := gbEncodeTable()
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			b = append(b, byte(r))
+		case r < 0x10000 && table[r] != 0:
+			b = append(b, byte(table[r]>>8), byte(table[r]))
+		case !fourByte:
+			return nil, fmt.Errorf("character %q is not in GBK", r)
+		case r < 0x10000:
+			i := sort.Search(len(gb18030Ranges), func(i int) bool { return rune(gb18030Ranges[i][1]) > r }) - 1
+			b = appendGB4(b, int(gb18030Ranges[i][0])+int(r-rune(gb18030Ranges[i][1])))
+		default:
+			b = appendGB4(b, gbFourByteSupplementary+int(r-0x10000))
+		}
+	}
+	return b, nil
+}
+
+// appendGB4 追加四字节区序号对应的字节序列
+func appendGB4(b []byte, idx int) []byte {
+	b4 := byte(idx%10) + 0x30
+	idx /= 10
+	b3 := byte(idx%126) + 0x81
+	idx /= 126
+	b2 := byte(idx%10) + 0x30
+	return append(b, byte(idx/10)+0x81, b2, b3, b4)
+}
+
+// decodeUTF16 按UTF-16解码, 数据长度必须是偶数
+func decodeUTF16(data []byte, order binary.ByteOrder) (string, error) {
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("UTF-16 data has odd length %d", len(data))
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// encodeUTF16 按UTF-16编码
+func encodeUTF16(s string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, len(units)*2)
+	for i, u := range units {
+		order.PutUint16(b[2*i:], u)
+	}
+	return b
+}
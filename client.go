@@ -0,0 +1,324 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMinBackoff 重连的初始等待时间
+	defaultMinBackoff = time.Second
+	// defaultMaxBackoff 重连的最长等待时间
+	defaultMaxBackoff = time.Minute
+)
+
+var (
+	// ErrNotConnected 客户端未连接或尚未完成登录
+	ErrNotConnected = errors.New("未连接")
+	// ErrConnectionLost 等待应答时连接断开
+	ErrConnectionLost = errors.New("连接已断开")
+)
+
+// DialFunc 建立连接的方法, 与net.Dialer.DialContext和tls.Dialer.DialContext的签名相同
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// LoginFunc 登录流程, 每次连接(包括重连)成功后执行; 返回错误时断开连接并等待重连.
+// 登录完成前 Client.Send 和 Client.Call 返回 ErrNotConnected, 登录流程应使用conn收发数据
+type LoginFunc func(ctx context.Context, conn *ClientConn) error
+
+// Client 客户端(设备模式), 主动连接平台, 使用与服务端相同的协议处理收到的数据单元,
+// 断开后按指数退避加随机抖动重连, 并在每次重连后重新执行登录流程
+type Client struct {
+	protocol   Protocol
+	addr       string
+	dial       DialFunc
+	login      LoginFunc
+	logger     *slog.Logger
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu        sync.Mutex
+	conn      *ClientConn   // 已登录的连接, 未连接时为nil
+	connected chan struct{} // conn可用时关闭
+}
+
+// NewClient 创建客户端, addr为TCP地址
+func NewClient(protocol Protocol, addr string) *Client {
+	var dialer net.Dialer
+	return &Client{
+		protocol:   protocol,
+		addr:       addr,
+		dial:       dialer.DialContext,
+		logger:     discardLogger,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		connected:  make(chan struct{}),
+	}
+}
+
+// SetDialer 设置建立连接的方法, 如使用tls.Dialer连接TLS服务端
+func (c *Client) SetDialer(dial DialFunc) *Client {
+	c.dial = dial
+	return c
+}
+
+// SetLogin 设置登录流程
+func (c *Client) SetLogin(login LoginFunc) *Client {
+	c.login = login
+	return c
+}
+
+// SetBackoff 设置重连的初始和最长等待时间, 默认1秒和1分钟
+func (c *Client) SetBackoff(min, max time.Duration) *Client {
+	c.minBackoff = min
+	c.maxBackoff = max
+	return c
+}
+
+// SetLogger 设置客户端的日志记录器, 记录连接和重连; 会话的日志由协议的记录器输出
+func (c *Client) SetLogger(logger *slog.Logger) *Client {
+	if logger == nil {
+		logger = discardLogger
+	}
+	c.logger = logger
+	return c
+}
+
+// Run 连接并处理收到的数据单元, 断开后自动重连, 直到上下文取消
+func (c *Client) Run(ctx context.Context) error {
+	attempt := 0
+	for {
+		loggedIn, err := c.runConn(ctx)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		// 登录成功过的连接断开后从初始等待时间开始重连
+		if loggedIn {
+			attempt = 0
+		}
+		delay := c.backoff(attempt)
+		attempt++
+		c.logger.Warn("连接断开, 等待重连", "addr", c.addr, "delay", delay, "err", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff 第attempt次重连前的等待时间: 指数增长到最长等待时间, 再在后一半内随机抖动, 避免大量设备同时重连
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.maxBackoff
+	if attempt < 32 {
+		if exp := c.minBackoff << attempt; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// runConn 建立一个连接, 登录后处理数据单元直到连接断开, 返回是否登录成功
+func (c *Client) runConn(ctx context.Context) (bool, error) {
+	conn, err := c.dial(ctx, "tcp", c.addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cc := &ClientConn{
+		pending: make(map[FunctionCode][]chan callResult),
+		done:    make(chan struct{}),
+	}
+	started := make(chan struct{})
+	served := make(chan error, 1)
+	go func() {
+		defer close(cc.done)
+		served <- serveWithSession(ctx, c.protocol, conn, func(session *Session) {
+			cc.session = session
+			session.onFrame = cc.deliver
+			close(started)
+		})
+	}()
+	<-started
+	c.logger.Info("已连接", "addr", c.addr)
+
+	if c.login != nil {
+		if err := c.login(ctx, cc); err != nil {
+			cancel()
+			<-cc.done
+			return false, err
+		}
+	}
+	c.setConn(cc)
+	defer c.setConn(nil)
+	return true, <-served
+}
+
+// setConn 设置当前可用的连接, nil表示连接断开
+func (c *Client) setConn(cc *ClientConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cc != nil {
+		c.conn = cc
+		close(c.connected)
+		return
+	}
+	c.conn = nil
+	c.connected = make(chan struct{})
+}
+
+// Conn 返回当前已登录的连接
+func (c *Client) Conn() (*ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+	return c.conn, nil
+}
+
+// WaitConnected 等待连接并登录成功
+func (c *Client) WaitConnected(ctx context.Context) (*ClientConn, error) {
+	for {
+		c.mu.Lock()
+		cc, connected := c.conn, c.connected
+		c.mu.Unlock()
+		if cc != nil {
+			return cc, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-connected:
+		}
+	}
+}
+
+// Send 通过当前连接发送一个完整的帧, 未连接时返回 ErrNotConnected
+func (c *Client) Send(code FunctionCode, frame []byte) error {
+	cc, err := c.Conn()
+	if err != nil {
+		return err
+	}
+	return cc.Send(code, frame)
+}
+
+// Call 通过当前连接发送一个完整的帧并等待功能码为reply的应答, 未连接时返回 ErrNotConnected
+func (c *Client) Call(ctx context.Context, code FunctionCode, frame []byte, reply FunctionCode) (*Frame, error) {
+	cc, err := c.Conn()
+	if err != nil {
+		return nil, err
+	}
+	return cc.Call(ctx, code, frame, reply)
+}
+
+// ClientConn 客户端的一个连接, 重连后是新的ClientConn
+type ClientConn struct {
+	session *Session
+	done    chan struct{}
+
+	mu      sync.Mutex
+	pending map[FunctionCode][]chan callResult // 按应答功能码等待的Call, 先发起的先收到
+}
+
+// Session 返回连接的会话
+func (cc *ClientConn) Session() *Session {
+	return cc.session
+}
+
+// Send 发送一个完整的帧
+func (cc *ClientConn) Send(code FunctionCode, frame []byte) error {
+	return cc.session.Send(code, frame)
+}
+
+// callResult 交给Call的应答或解析应答负载的错误
+type callResult struct {
+	frame *Frame
+	err   error
+}
+
+// Call 发送一个完整的帧并等待功能码为reply的应答; 应答交给Call, 不再调用该功能码的处理函数.
+// 应答负载解析失败时返回解析错误, 连接断开时返回 ErrConnectionLost
+func (cc *ClientConn) Call(ctx context.Context, code FunctionCode, frame []byte, reply FunctionCode) (*Frame, error) {
+	ch := make(chan callResult, 1)
+	cc.mu.Lock()
+	cc.pending[reply] = append(cc.pending[reply], ch)
+	cc.mu.Unlock()
+	if err := cc.Send(code, frame); err != nil {
+		cc.cancel(reply, ch)
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		return r.frame, r.err
+	case <-ctx.Done():
+		cc.cancel(reply, ch)
+		return nil, context.Cause(ctx)
+	case <-cc.done:
+		cc.cancel(reply, ch)
+		// 断开前可能已收到应答
+		select {
+		case r := <-ch:
+			return r.frame, r.err
+		default:
+			return nil, ErrConnectionLost
+		}
+	}
+}
+
+// cancel 取消等待
+func (cc *ClientConn) cancel(reply FunctionCode, ch chan callResult) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	waiters := cc.pending[reply]
+	for i, w := range waiters {
+		if w == ch {
+			cc.pending[reply] = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+}
+
+// deliver 将数据单元或解析它的错误交给最早等待该功能码的Call
+func (cc *ClientConn) deliver(frame *Frame, err error) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	code := frame.Code()
+	waiters := cc.pending[code]
+	if len(waiters) == 0 {
+		return false
+	}
+	if err != nil {
+		frame = nil
+	}
+	waiters[0] <- callResult{frame: frame, err: err}
+	cc.pending[code] = waiters[1:]
+	return true
+}
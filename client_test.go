@@ -0,0 +1,210 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_ReconnectAndLogin(t *testing.T) {
+	login := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	loginReply := []byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61}
+	push := []byte{0x68, 0x06, 0x00, 0x03, 0x34, 0x35, 0x36, 0x37, 0x0c, 0x53}
+	asciiField := func(fh *FunctionHandler) {
+		fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+	}
+
+	// 平台: 收到登录帧后回复0x04, 其他0x03帧原样推回
+	platform, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			if hc.Parsed["ascii"].Explained == "0123" {
+				hc.Session.SetDeviceID("pile")
+				return hc.Session.Send(0x04, loginReply)
+			}
+			return hc.Session.Send(0x03, hc.Frame.Raw())
+		}, asciiField).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(platform)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx, ln)
+
+	pushed := make(chan string, 4)
+	device, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			pushed <- hc.Parsed["ascii"].Explained.(string)
+			return nil
+		}, asciiField).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logins atomic.Int32
+	client := NewClient(device, ln.Addr().String()).
+		SetBackoff(10*time.Millisecond, 50*time.Millisecond).
+		SetLogin(func(ctx context.Context, conn *ClientConn) error {
+			reply, err := conn.Call(ctx, 0x03, login, 0x04)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(reply.Raw(), loginReply) {
+				t.Errorf("unexpected login reply: % X", reply.Raw())
+			}
+			logins.Add(1)
+			return nil
+		})
+
+	if err := client.Send(0x03, push); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("want ErrNotConnected before Run, got %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx)
+	}()
+
+	for round := 1; round <= 2; round++ {
+		waitCtx, waitCancel := context.WithTimeout(ctx, 2*time.Second)
+		if _, err := client.WaitConnected(waitCtx); err != nil {
+			t.Fatalf("round %d: %v", round, err)
+		}
+		waitCancel()
+		if n := logins.Load(); n != int32(round) {
+			t.Fatalf("round %d: want %d logins, got %d", round, round, n)
+		}
+		// 没有等待中的Call时, 收到的帧交给处理函数
+		if err := client.Send(0x03, push); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-pushed:
+			if got != "4567" {
+				t.Fatalf("unexpected pushed frame %q", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("pushed frame not handled")
+		}
+		// 平台断开连接, 客户端重连并重新登录
+		session, ok := server.Session("pile")
+		if !ok {
+			t.Fatalf("round %d: device not registered on platform", round)
+		}
+		session.Conn().Close()
+		for {
+			if _, err := client.Conn(); errors.Is(err, ErrNotConnected) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("want context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Client.Run did not stop after cancellation")
+	}
+}
+
+func TestClient_Backoff(t *testing.T) {
+	client := NewClient(nil, "").SetBackoff(100*time.Millisecond, time.Second)
+	for attempt, want := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for range 20 {
+			if d := client.backoff(attempt); d < want/2 || d > want {
+				t.Fatalf("attempt %d: backoff %v not in [%v, %v]", attempt, d, want/2, want)
+			}
+		}
+	}
+	if d := client.backoff(100); d < 500*time.Millisecond || d > time.Second {
+		t.Fatalf("backoff overflowed: %v", d)
+	}
+}
+
+func TestClientConn_CallParseError(t *testing.T) {
+	asciiField := func(fh *FunctionHandler) {
+		fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+	}
+	// 平台的应答负载只有3个字节, 与客户端定义的字段长度不符
+	platform, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			return hc.Session.Send(0x04, testFrame(0x04, []byte("012")))
+		}, asciiField).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewServer(platform).Serve(ctx, ln)
+
+	device, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x04), func(hc *HandlerContext) error {
+			return nil
+		}, asciiField).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		reply *Frame
+		err   error
+	}
+	results := make(chan result, 1)
+	client := NewClient(device, ln.Addr().String()).
+		SetLogin(func(ctx context.Context, conn *ClientConn) error {
+			reply, err := conn.Call(ctx, 0x03, testFrame(0x03, []byte("0123")), 0x04)
+			select {
+			case results <- result{reply, err}:
+			default:
+			}
+			return nil
+		})
+	go client.Run(ctx)
+
+	select {
+	case r := <-results:
+		if r.reply != nil || !errors.Is(r.err, ErrLengthMismatch) {
+			t.Fatalf("want ErrLengthMismatch, got %v, %v", r.reply, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Call did not return")
+	}
+}
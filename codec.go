@@ -15,11 +15,17 @@
 package rot
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"math/bits"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // type DataTyper interface {
@@ -27,10 +33,17 @@ import (
 // 	ExplainedValue(src any) any
 // }
 
+// checkedDataTyper 解释或反解释可能失败的解释器, 错误作为字段的编解码错误返回而不是panic
+type checkedDataTyper interface {
+	explain(data any) (any, error)
+	unexplain(data any) (any, error)
+}
+
 // BIN码 可以解释为整数、浮点数
 // BCD码 可以解释为字符串、整数（max: 18446744073709551615）、浮点数
-// ASCII 只能解释为字符串
-// CP56TIME2A 可以解释为字符串
+// ASCII 只能解释为字符串, 其他字符集的字符串见 CodecString
+// HEX 解释为十六进制字符串, RAW 为原始字节
+// CP56TIME2A、BCD时间、Unix时间戳 解码为time.Time(见 CodecTime)
 
 // Codec 编解码器接口
 type Codec interface {
@@ -39,39 +52,100 @@ type Codec interface {
 	Decode(data []byte) (any, error)
 }
 
-// CodecBIN BIN编解码器
+// CodecBIN BIN编解码器, 默认按补码解码为有符号的int, 无符号时解码为uint64
 type CodecBIN struct {
-	order binary.ByteOrder
+	order    binary.ByteOrder
+	unsigned bool
 }
 
 func NewCodecBIN(order binary.ByteOrder) *CodecBIN {
 	return &CodecBIN{order: order}
 }
 
+// NewCodecUBIN 创建无符号BIN编解码器
+func NewCodecUBIN(order binary.ByteOrder) *CodecBIN {
+	return &CodecBIN{order: order, unsigned: true}
+}
+
 func (c *CodecBIN) Configure() {
 	// 初始化操作（如果需要）
 }
 
+// Encode 编码整数, 超出字段长度的表示范围(有符号时为补码范围)时返回 ErrLength
 func (c *CodecBIN) Encode(data any, byteLength int) ([]byte, error) {
+	if byteLength < 1 || byteLength > 8 {
+		return nil, fmt.Errorf("BIN encoding needs a field length of 1-8 bytes, got %d", byteLength)
+	}
+	var (
+		n        int64
+		u        uint64
+		negative bool
+	)
 	// 根据数据类型处理编码
-	v, ok := data.(int)
-	if !ok {
+	switch v := data.(type) {
+	case int:
+		n, negative = int64(v), v < 0
+	case int8:
+		n, negative = int64(v), v < 0
+	case int16:
+		n, negative = int64(v), v < 0
+	case int32:
+		n, negative = int64(v), v < 0
+	case int64:
+		n, negative = v, v < 0
+	case uint:
+		u = uint64(v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		u = v
+	default:
 		return nil, fmt.Errorf("unsupported data type for BIN encoding: %T", data)
 	}
-	return Int2Bin(v, byte(byteLength), c.order), nil
+	if n > 0 {
+		u = uint64(n)
+	}
+	if c.unsigned {
+		if negative {
+			return nil, fmt.Errorf("negative value %d for unsigned BIN field: %w", n, ErrLength)
+		}
+		return Uint2BIN(u, uint8(byteLength), c.order)
+	}
+	if negative {
+		return Int2BIN(n, uint8(byteLength), c.order)
+	}
+	if u > math.MaxInt64 {
+		return nil, fmt.Errorf("value %d overflows signed BIN field: %w", u, ErrLength)
+	}
+	return Int2BIN(int64(u), uint8(byteLength), c.order)
 }
 
 func (c *CodecBIN) Decode(data []byte) (any, error) {
 	if len(data) == 0 {
 		return nil, errors.New("empty data for decoding")
 	}
-	src := Bin2Int(data, c.order)
-	return src, nil
+	u, err := BIN2Uint64(data, c.order)
+	if err != nil {
+		return nil, err
+	}
+	if c.unsigned {
+		return u, nil
+	}
+	// 符号扩展
+	shift := 64 - 8*uint(len(data))
+	return int(int64(u<<shift) >> shift), nil
 }
 
-// CodecBCD BCD编解码器
+// CodecBCD BCD编解码器, 每个字节表示两位十进制数字, 小端序时字节顺序相反.
+// scale 为0时解码为数字字符串(保留前导0, 如桩编号); scale 大于0时为定点小数,
+// 最后 scale 位是小数位, 解码为去掉前导0的小数字符串
 type CodecBCD struct {
 	order binary.ByteOrder
+	scale int
 }
 
 func NewCodecBCD(order binary.ByteOrder) *CodecBCD {
@@ -82,17 +156,114 @@ func (c *CodecBCD) Configure() {
 	// 初始化操作（如果需要）
 }
 
+// Encode 编码非负整数、浮点数或数字字符串, 左侧补0到字段长度, 超出字段长度时返回 ErrLength.
+// 浮点数按最短的十进制表示与字符串一样处理: 可以包含小数点, 小数位数不能超过 scale, 不做舍入
 func (c *CodecBCD) Encode(data any, byteLength int) ([]byte, error) {
+	if byteLength < 1 {
+		return nil, fmt.Errorf("BCD encoding needs a positive field length, got %d", byteLength)
+	}
+	var (
+		digits string
+		n      int64
+		u      uint64
+		isInt  = true
+	)
 	switch v := data.(type) {
 	case int:
-		return hex.DecodeString(strconv.Itoa(v))
+		n = int64(v)
+	case int8:
+		n = int64(v)
+	case int16:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint:
+		u = uint64(v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		u = v
+	default:
+		isInt = false
+	}
+	if isInt {
+		if n < 0 {
+			return nil, fmt.Errorf("negative value %d for BCD field", n)
+		}
+		if n > 0 {
+			u = uint64(n)
+		}
+		data = strconv.FormatUint(u, 10)
+	}
+	switch v := data.(type) {
+	case float32:
+		d, err := c.floatDigits(float64(v), 32)
+		if err != nil {
+			return nil, err
+		}
+		digits = d
 	case float64:
-		return hex.DecodeString(strconv.FormatFloat(v, 'f', -1, 64))
+		d, err := c.floatDigits(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		digits = d
 	case string:
-		return hex.DecodeString(v)
+		d, err := c.decimalDigits(v)
+		if err != nil {
+			return nil, err
+		}
+		digits = d
 	default:
 		return nil, fmt.Errorf("unsupported data type for BCD encoding: %T", data)
 	}
+	digits = strings.TrimLeft(digits, "0")
+	if len(digits) > byteLength*2 {
+		return nil, fmt.Errorf("BCD value %s overflows %d bytes: %w", digits, byteLength, ErrLength)
+	}
+	digits = strings.Repeat("0", byteLength*2-len(digits)) + digits
+	b := make([]byte, byteLength)
+	for i := range b {
+		b[i] = (digits[2*i]-'0')<<4 | (digits[2*i+1] - '0')
+	}
+	if isLittleEndian(c.order) {
+		slices.Reverse(b)
+	}
+	return b, nil
+}
+
+// floatDigits 将浮点数的最短十进制表示按 scale 位小数转换为不含小数点的数字串
+func (c *CodecBCD) floatDigits(v float64, bitSize int) (string, error) {
+	if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", fmt.Errorf("invalid value %v for BCD field", v)
+	}
+	return c.decimalDigits(strconv.FormatFloat(v, 'f', -1, bitSize))
+}
+
+// decimalDigits 将数字字符串按 scale 位小数转换为不含小数点的数字串
+func (c *CodecBCD) decimalDigits(s string) (string, error) {
+	intPart, fracPart, hasPoint := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" || hasPoint && fracPart == "" {
+		return "", fmt.Errorf("invalid BCD value %q", s)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("invalid BCD value %q: non-digit %q", s, r)
+		}
+	}
+	if len(fracPart) > c.scale {
+		if strings.Trim(fracPart[c.scale:], "0") != "" {
+			return "", fmt.Errorf("BCD value %q has more than %d decimal places", s, c.scale)
+		}
+		fracPart = fracPart[:c.scale]
+	}
+	return intPart + fracPart + strings.Repeat("0", c.scale-len(fracPart)), nil
 }
 
 func (c *CodecBCD) Decode(data []byte) (any, error) {
@@ -100,47 +271,286 @@ func (c *CodecBCD) Decode(data []byte) (any, error) {
 		return nil, errors.New("empty data for decoding")
 	}
 	// 根据字节序处理数据
-	if c.order == binary.LittleEndian && len(data) > 1 {
+	b := data
+	if isLittleEndian(c.order) && len(data) > 1 {
 		// 小端序需要反转字节顺序
-		reversed := make([]byte, len(data))
-		for i := range len(reversed) {
-			reversed[i] = data[len(data)-1-i]
+		b = slices.Clone(data)
+		slices.Reverse(b)
+	}
+	digits := hex.EncodeToString(b)
+	if c.scale == 0 {
+		return digits, nil
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid BCD data % X", data)
+		}
+	}
+	if len(digits) <= c.scale {
+		digits = strings.Repeat("0", c.scale-len(digits)+1) + digits
+	}
+	point := len(digits) - c.scale
+	intPart := strings.TrimLeft(digits[:point], "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	return intPart + "." + digits[point:], nil
+}
+
+// PadSide 定长字符串的填充位置
+type PadSide int
+
+const (
+	PadRight PadSide = iota // 左对齐, 填充在右侧
+	PadLeft                 // 右对齐, 填充在左侧
+)
+
+// CodecString 字符串编解码器. 解码时去掉填充一侧的填充字符再按字符集解码,
+// 编码时按字符集编码后用填充字符补齐到字段长度, 超出字段长度时返回 ErrLength.
+// 默认用0x00在右侧填充; UTF-16的填充字符占两个字节
+type CodecString struct {
+	charset Charset
+	pad     byte
+	side    PadSide
+}
+
+func NewCodecString(charset Charset, pad byte, side PadSide) *CodecString {
+	return &CodecString{charset: charset, pad: pad, side: side}
+}
+
+func (c *CodecString) Configure() {
+	// 初始化操作（如果需要）
+}
+
+// padUnit 填充字符按字符集编码后的字节
+func (c *CodecString) padUnit() []byte {
+	switch c.charset {
+	case CharsetUTF16LE:
+		return []byte{c.pad, 0x00}
+	case CharsetUTF16BE:
+		return []byte{0x00, c.pad}
+	default:
+		return []byte{c.pad}
+	}
+}
+
+func (c *CodecString) Encode(data any, byteLength int) ([]byte, error) {
+	v, ok := data.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported data type for %s encoding: %T", c.charset, data)
+	}
+	var b []byte
+	switch c.charset {
+	case CharsetASCII:
+		for i := range len(v) {
+			if v[i] >= utf8.RuneSelf {
+				return nil, fmt.Errorf("non-ASCII string %q", v)
+			}
+		}
+		b = []byte(v)
+	case CharsetUTF8:
+		b = []byte(v)
+	case CharsetGBK, CharsetGB18030:
+		encoded, err := encodeGB(v, c.charset == CharsetGB18030)
+		if err != nil {
+			return nil, err
+		}
+		b = encoded
+	case CharsetUTF16LE:
+		b = encodeUTF16(v, binary.LittleEndian)
+	case CharsetUTF16BE:
+		b = encodeUTF16(v, binary.BigEndian)
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", c.charset)
+	}
+	if len(b) > byteLength {
+		return nil, fmt.Errorf("%s string %q needs %d bytes, field has %d: %w", c.charset, v, len(b), byteLength, ErrLength)
+	}
+	unit := c.padUnit()
+	if (byteLength-len(b))%len(unit) != 0 {
+		return nil, fmt.Errorf("%s field length %d is not a multiple of %d: %w", c.charset, byteLength, len(unit), ErrLength)
+	}
+	padding := bytes.Repeat(unit, (byteLength-len(b))/len(unit))
+	if c.side == PadLeft {
+		return append(padding, b...), nil
+	}
+	return append(b, padding...), nil
+}
+
+func (c *CodecString) Decode(data []byte) (any, error) {
+	unit := c.padUnit()
+	if c.side == PadLeft {
+		for bytes.HasPrefix(data, unit) {
+			data = data[len(unit):]
 		}
-		return hex.EncodeToString(reversed), nil
 	} else {
-		// 大端序（默认）直接使用原数据
-		return hex.EncodeToString(data), nil
+		for bytes.HasSuffix(data, unit) {
+			data = data[:len(data)-len(unit)]
+		}
+	}
+	switch c.charset {
+	case CharsetASCII:
+		return string(data), nil
+	case CharsetUTF8:
+		return strings.ToValidUTF8(string(data), string(utf8.RuneError)), nil
+	case CharsetGBK, CharsetGB18030:
+		return decodeGB(data, c.charset == CharsetGB18030), nil
+	case CharsetUTF16LE:
+		return decodeUTF16(data, binary.LittleEndian)
+	case CharsetUTF16BE:
+		return decodeUTF16(data, binary.BigEndian)
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", c.charset)
 	}
 }
 
-// CodecASCII ASCII编解码器
+// CodecASCII ASCII编解码器, 去掉右侧的0x00填充, 编码时用0x00补齐到字段长度
 type CodecASCII struct {
-	// ASCII编解码器配置
+	CodecString
 }
 
 func NewCodecASCII() *CodecASCII {
 	return &CodecASCII{}
 }
 
-func (c *CodecASCII) Configure() {
+// CodecHEX HEX编解码器, 每个字节解码为两位十六进制字符, 默认大写; 小端序时按相反的字节顺序解码
+type CodecHEX struct {
+	order binary.ByteOrder
+	lower bool
+}
+
+func NewCodecHEX(order binary.ByteOrder, lower bool) *CodecHEX {
+	return &CodecHEX{order: order, lower: lower}
+}
+
+func (c *CodecHEX) Configure() {
 	// 初始化操作（如果需要）
 }
 
-func (c *CodecASCII) Encode(data any, byteLength int) ([]byte, error) {
-	// 根据数据类型处理编码
-	v, ok := data.(string)
-	if !ok {
-		return nil, fmt.Errorf("unsupported data type for ASCII encoding: %T", data)
+// Encode 编码十六进制字符串(大小写均可)或字节切片, 长度必须与字段长度一致
+func (c *CodecHEX) Encode(data any, byteLength int) ([]byte, error) {
+	var b []byte
+	switch v := data.(type) {
+	case string:
+		decoded, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex string %q: %w", v, err)
+		}
+		b = decoded
+	case []byte:
+		b = bytes.Clone(v)
+	default:
+		return nil, fmt.Errorf("unsupported data type for HEX encoding: %T", data)
+	}
+	if len(b) != byteLength {
+		return nil, fmt.Errorf("HEX value has %d bytes, field needs %d: %w", len(b), byteLength, ErrLength)
 	}
-	// 确保字符串长度不超过指定长度
-	if len(v) > byteLength {
-		v = v[:byteLength]
+	if isLittleEndian(c.order) {
+		slices.Reverse(b)
 	}
-	return []byte(v), nil
+	return b, nil
 }
 
-func (c *CodecASCII) Decode(data []byte) (any, error) {
-	return string(data), nil
+func (c *CodecHEX) Decode(data []byte) (any, error) {
+	b := data
+	if isLittleEndian(c.order) {
+		b = slices.Clone(data)
+		slices.Reverse(b)
+	}
+	if c.lower {
+		return hex.EncodeToString(b), nil
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// CodecRaw 原始字节编解码器, 解码为 []byte(副本), 用于MAC地址、卡号、令牌等不需要解释的字段
+type CodecRaw struct{}
+
+func NewCodecRaw() *CodecRaw {
+	return &CodecRaw{}
+}
+
+func (c *CodecRaw) Configure() {
+	// 初始化操作（如果需要）
+}
+
+// Encode 编码字节切片或字符串, 长度必须与字段长度一致
+func (c *CodecRaw) Encode(data any, byteLength int) ([]byte, error) {
+	var b []byte
+	switch v := data.(type) {
+	case []byte:
+		b = bytes.Clone(v)
+	case string:
+		b = []byte(v)
+	default:
+		return nil, fmt.Errorf("unsupported data type for raw encoding: %T", data)
+	}
+	if len(b) != byteLength {
+		return nil, fmt.Errorf("raw value has %d bytes, field needs %d: %w", len(b), byteLength, ErrLength)
+	}
+	return b, nil
+}
+
+func (c *CodecRaw) Decode(data []byte) (any, error) {
+	return bytes.Clone(data), nil
+}
+
+// CodecFloat IEEE 754浮点数编解码器, 32位解码为float32, 64位解码为float64;
+// 字节序可使用 BigEndianWordSwap 等字交换字节序
+type CodecFloat struct {
+	bits  int
+	order binary.ByteOrder
+}
+
+// NewCodecFloat 创建浮点数编解码器, bits只能为32或64
+func NewCodecFloat(bits int, order binary.ByteOrder) *CodecFloat {
+	if bits != 32 && bits != 64 {
+		panic(fmt.Sprintf("float bits %d must be 32 or 64", bits))
+	}
+	return &CodecFloat{bits: bits, order: order}
+}
+
+func (c *CodecFloat) Configure() {
+	// 初始化操作（如果需要）
+}
+
+// Encode 编码浮点数, 字段长度与位数不符或有限值超出float32范围时返回 ErrLength
+func (c *CodecFloat) Encode(data any, byteLength int) ([]byte, error) {
+	size := c.bits / 8
+	if byteLength != 0 && byteLength != size {
+		return nil, fmt.Errorf("float%d needs %d bytes, field length is %d: %w", c.bits, size, byteLength, ErrLength)
+	}
+	var f float64
+	switch v := data.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	default:
+		return nil, fmt.Errorf("unsupported data type for float encoding: %T", data)
+	}
+	if c.bits == 32 && math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+		return nil, fmt.Errorf("value %g overflows float32: %w", f, ErrLength)
+	}
+	b := make([]byte, size)
+	if c.bits == 32 {
+		c.order.PutUint32(b, math.Float32bits(float32(f)))
+	} else {
+		c.order.PutUint64(b, math.Float64bits(f))
+	}
+	return b, nil
+}
+
+func (c *CodecFloat) Decode(data []byte) (any, error) {
+	if len(data) != c.bits/8 {
+		return nil, fmt.Errorf("float%d needs %d bytes, got %d: %w", c.bits, c.bits/8, len(data), ErrLength)
+	}
+	if c.bits == 32 {
+		return math.Float32frombits(c.order.Uint32(data)), nil
+	}
+	return math.Float64frombits(c.order.Uint64(data)), nil
 }
 
 // BIN   - INT     explain(int -> int)
@@ -162,41 +572,199 @@ type dtInteger struct {
 }
 
 var (
-	_ CodecOption = (*dtInteger)(nil)
-	_ DataTyper   = (*dtInteger)(nil)
+	_ CodecOption      = (*dtInteger)(nil)
+	_ DataTyper        = (*dtInteger)(nil)
+	_ checkedDataTyper = (*dtInteger)(nil)
 )
 
 func (t *dtInteger) Explain(data any) any {
-	var i int
+	v, err := t.explain(data)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (t *dtInteger) UnExplain(data any) any {
+	v, err := t.unexplain(data)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// explain 应用倍数和偏移量. 无符号字段(uint64)的结果总是uint64, 结果为负或超出uint64时返回错误;
+// 其他字段的结果为int, 超出int时返回错误
+func (t *dtInteger) explain(data any) (any, error) {
 	switch v := data.(type) {
-	default:
-		panic(fmt.Sprintf("unsupported data type for binInteger: %T", data))
 	case int:
-		i = v
+		return t.apply(v)
+	case uint64:
+		var (
+			r  uint64
+			ok bool
+		)
+		if t.moflag {
+			if r, ok = mulUint(v, t.multiple); ok {
+				r, ok = addUint(r, t.offset)
+			}
+		} else {
+			if r, ok = addUint(v, t.offset); ok {
+				r, ok = mulUint(r, t.multiple)
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("integer %d with multiple %d and offset %d is out of the uint64 range", v, t.multiple, t.offset)
+		}
+		return r, nil
 	case string:
-		srcInt, err := strconv.Atoi(v)
+		i, err := strconv.Atoi(v)
 		if err != nil {
-			return nil
+			return nil, fmt.Errorf("invalid integer %q: %w", v, err)
 		}
-		i = srcInt
+		return t.apply(i)
+	default:
+		return nil, fmt.Errorf("unsupported data type for binInteger: %T", data)
 	}
-	// 应用倍数和偏移量
+}
+
+// apply 在int中应用倍数和偏移量
+func (t *dtInteger) apply(i int) (any, error) {
+	var (
+		r  int
+		ok bool
+	)
 	if t.moflag {
-		return i*t.multiple + t.offset
+		if r, ok = mulInt(i, t.multiple); ok {
+			r, ok = addInt(r, t.offset)
+		}
 	} else {
-		return (i + t.offset) * t.multiple
+		if r, ok = addInt(i, t.offset); ok {
+			r, ok = mulInt(r, t.multiple)
+		}
 	}
+	if !ok {
+		return nil, fmt.Errorf("integer %d with multiple %d and offset %d overflows int", i, t.multiple, t.offset)
+	}
+	return r, nil
 }
 
-func (t *dtInteger) UnExplain(data any) any {
-	srcFloat := data.(int)
-	result := 0
+// unexplain 去掉倍数和偏移量, 接受所有整数类型; 超出int的无符号数在uint64中计算并返回uint64
+func (t *dtInteger) unexplain(data any) (any, error) {
+	if t.multiple == 0 {
+		return nil, errors.New("integer multiple is 0")
+	}
+	var i int64
+	switch v := data.(type) {
+	case int:
+		i = int64(v)
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint8:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case uint:
+		return t.unexplainUint(uint64(v))
+	case uint64:
+		return t.unexplainUint(v)
+	default:
+		return nil, fmt.Errorf("unsupported data type for binInteger encoding: %T", data)
+	}
+	if i > math.MaxInt || i < math.MinInt {
+		return nil, fmt.Errorf("integer %d overflows int", i)
+	}
+	n := int(i)
 	if t.moflag {
-		result = (srcFloat - t.offset) / t.multiple
+		r, ok := addInt(n, -t.offset)
+		if !ok || t.offset == math.MinInt {
+			return nil, fmt.Errorf("integer %d with offset %d overflows int", n, t.offset)
+		}
+		return r / t.multiple, nil
+	}
+	r, ok := addInt(n/t.multiple, -t.offset)
+	if !ok || t.offset == math.MinInt {
+		return nil, fmt.Errorf("integer %d with offset %d overflows int", n, t.offset)
+	}
+	return r, nil
+}
+
+// unexplainUint 去掉无符号数的倍数和偏移量, 能用int表示时返回int
+func (t *dtInteger) unexplainUint(v uint64) (any, error) {
+	if v <= math.MaxInt {
+		return t.unexplain(int(v))
+	}
+	if t.multiple < 0 || t.offset == math.MinInt {
+		return nil, fmt.Errorf("integer %d with multiple %d and offset %d is out of range", v, t.multiple, t.offset)
+	}
+	var (
+		r  uint64
+		ok bool
+	)
+	if t.moflag {
+		if r, ok = addUint(v, -t.offset); ok {
+			r /= uint64(t.multiple)
+		}
 	} else {
-		result = (srcFloat / t.multiple) - t.offset
+		r, ok = addUint(v/uint64(t.multiple), -t.offset)
 	}
-	return result
+	if !ok {
+		return nil, fmt.Errorf("integer %d with offset %d is out of the uint64 range", v, t.offset)
+	}
+	return r, nil
+}
+
+// addInt 计算 a+b, 溢出时返回false
+func addInt(a, b int) (int, bool) {
+	if b > 0 && a > math.MaxInt-b || b < 0 && a < math.MinInt-b {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// mulInt 计算 a×b, 溢出时返回false
+func mulInt(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	r := a * b
+	if r/b != a || a == -1 && b == math.MinInt || b == -1 && a == math.MinInt {
+		return 0, false
+	}
+	return r, true
+}
+
+// addUint 计算 v+n, 结果为负或溢出时返回false
+func addUint(v uint64, n int) (uint64, bool) {
+	if n >= 0 {
+		r, carry := bits.Add64(v, uint64(n), 0)
+		return r, carry == 0
+	}
+	d := absUint64(int64(n))
+	if v < d {
+		return 0, false
+	}
+	return v - d, true
+}
+
+// mulUint 计算 v×n, 结果为负或溢出时返回false
+func mulUint(v uint64, n int) (uint64, bool) {
+	if v == 0 || n == 0 {
+		return 0, true
+	}
+	if n < 0 {
+		return 0, false
+	}
+	hi, lo := bits.Mul64(v, uint64(n))
+	return lo, hi == 0
 }
 
 func (t *dtInteger) Apply(config *FieldCodecConfig) {
@@ -215,24 +783,30 @@ var (
 )
 
 func (t *dtFloat) Explain(data any) any {
-	var i int
+	var f float64
 	switch v := data.(type) {
 	default:
 		panic(fmt.Sprintf("unsupported data type for binFloat: %T", data))
 	case int:
-		i = v
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
 	case string:
-		srcInt, err := strconv.Atoi(v)
+		srcFloat, err := strconv.ParseFloat(v, 64)
 		if err != nil {
 			return nil
 		}
-		i = srcInt
+		f = srcFloat
 	}
 	result := 0.0
 	if t.moflag {
-		result = float64(i)*t.multiple + t.offset
+		result = f*t.multiple + t.offset
 	} else {
-		result = (float64(i) + t.offset) * t.multiple
+		result = (f + t.offset) * t.multiple
 	}
 	return result
 }
@@ -245,6 +819,10 @@ func (t *dtFloat) UnExplain(data any) any {
 		panic(fmt.Sprintf("unsupported data type for bcdFloat: %T", data))
 	case float64:
 		srcFloat = v
+	case float32:
+		srcFloat = float64(v)
+	case int:
+		srcFloat = float64(v)
 	case string:
 		srcFloat, err = strconv.ParseFloat(v, 64)
 		if err != nil {
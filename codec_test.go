@@ -15,9 +15,15 @@
 package rot
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -126,3 +132,398 @@ func TestCodec2_Parse2(t *testing.T) {
 func setHandlerConfig(builder *ProtocolBuilder) {
 
 }
+
+func TestCodecFloat(t *testing.T) {
+	cases := []struct {
+		name   string
+		option func(binary.ByteOrder) CodecOption
+		order  binary.ByteOrder
+		value  any
+		bytes  []byte
+	}{
+		{"float32 ABCD", WithFloat32WithOrder, binary.BigEndian, float32(123.456), []byte{0x42, 0xF6, 0xE9, 0x79}},
+		{"float32 DCBA", WithFloat32WithOrder, binary.LittleEndian, float32(123.456), []byte{0x79, 0xE9, 0xF6, 0x42}},
+		{"float32 CDAB", WithFloat32WithOrder, BigEndianWordSwap, float32(123.456), []byte{0xE9, 0x79, 0x42, 0xF6}},
+		{"float32 BADC", WithFloat32WithOrder, LittleEndianWordSwap, float32(123.456), []byte{0xF6, 0x42, 0x79, 0xE9}},
+		{"float64 ABCD", WithFloat64WithOrder, binary.BigEndian, -2.5, []byte{0xC0, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"float64 CDAB", WithFloat64WithOrder, BigEndianWordSwap, -2.5, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x04}},
+		{"float64 BADC", WithFloat64WithOrder, LittleEndianWordSwap, -2.5, []byte{0x04, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, c := range cases {
+		encoder := NewFieldCodecConfig(c.name, WithEncode(), c.option(c.order))
+		b, err := encoder.Encode(c.value)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(b, c.bytes) {
+			t.Fatalf("%s: encoded % X, want % X", c.name, b, c.bytes)
+		}
+		decoded, err := NewFieldCodecConfig(c.name, c.option(c.order)).Decode(b)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if decoded.Explained != c.value {
+			t.Fatalf("%s: decoded %v (%T), want %v", c.name, decoded.Explained, decoded.Explained, c.value)
+		}
+	}
+
+	// 与倍率解释器组合
+	scaled, err := NewFieldCodecConfig("scaled", WithFloat32WithOrder(binary.BigEndian), WithFloat(true, 0.5, 1)).Decode([]byte{0x41, 0x20, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scaled.Explained != 6.0 {
+		t.Fatalf("scaled float: got %v", scaled.Explained)
+	}
+	if _, err := NewFieldCodecConfig("short", WithFloat32()).Decode([]byte{0x41, 0x20}); !errors.Is(err, ErrLength) {
+		t.Fatalf("short float32: want ErrLength, got %v", err)
+	}
+	if _, err := NewFieldCodecConfig("length", WithEncode(), WithFloat32(), WithLength(8)).Encode(1.5); !errors.Is(err, ErrLength) {
+		t.Fatalf("float32 in 8 bytes: want ErrLength, got %v", err)
+	}
+	// 超出float32范围的有限值不能静默变为无穷大
+	if _, err := NewFieldCodecConfig("overflow", WithEncode(), WithFloat32()).Encode(1e39); !errors.Is(err, ErrLength) {
+		t.Fatalf("float32 overflow: want ErrLength, got %v", err)
+	}
+	if b, err := NewFieldCodecConfig("inf", WithEncode(), WithFloat32()).Encode(math.Inf(-1)); err != nil || !bytes.Equal(b, []byte{0xFF, 0x80, 0x00, 0x00}) {
+		t.Fatalf("float32 -Inf: got % X, %v", b, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("want panic for 16-bit float")
+			}
+		}()
+		NewCodecFloat(16, binary.BigEndian)
+	}()
+}
+
+func TestCodecBIN_Sign(t *testing.T) {
+	decode := func(data []byte, options ...CodecOption) any {
+		t.Helper()
+		decoded, err := NewFieldCodecConfig("bin", options...).Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded.Explained
+	}
+	if v := decode([]byte{0xFF, 0xFF}, WithBin(), WithUint()); v != uint64(65535) {
+		t.Fatalf("unsigned: got %v (%T)", v, v)
+	}
+	if v := decode([]byte{0xFF, 0xFF}, WithBin(), WithInt()); v != -1 {
+		t.Fatalf("signed: got %v (%T)", v, v)
+	}
+	if v := decode([]byte{0xFF, 0xFF, 0xFE}, WithBin()); v != -2 {
+		t.Fatalf("3-byte signed: got %v (%T)", v, v)
+	}
+	if v := decode([]byte{0x00, 0x01, 0x02}, WithBinWithOrder(binary.LittleEndian), WithUint()); v != uint64(0x020100) {
+		t.Fatalf("3-byte little endian: got %v (%T)", v, v)
+	}
+	maxBytes := bytes.Repeat([]byte{0xFF}, 8)
+	if v := decode(maxBytes, WithBin(), WithUint(), WithInteger(true, 1, 0)); v != uint64(math.MaxUint64) {
+		t.Fatalf("uint64 max: got %v (%T)", v, v)
+	}
+	// 无符号字段的结果总是uint64, 溢出时返回错误
+	if v := decode([]byte{0x00, 0x01}, WithBin(), WithUint(), WithInteger(true, 1, 0)); v != uint64(1) {
+		t.Fatalf("small unsigned: got %v (%T)", v, v)
+	}
+	for _, options := range [][]CodecOption{
+		{WithInteger(true, 2, 0)},
+		{WithInteger(true, 1, 1)},
+		{WithInteger(true, 1, -1), WithLength(2)},
+	} {
+		data := maxBytes
+		if len(options) > 1 {
+			data = []byte{0x00, 0x00}
+		}
+		if _, err := NewFieldCodecConfig("bin", append([]CodecOption{WithBin(), WithUint()}, options...)...).Decode(data); err == nil {
+			t.Fatalf("% X: want overflow error", data)
+		}
+	}
+	if _, err := NewFieldCodecConfig("bin", WithBin(), WithInteger(true, math.MaxInt, 0)).Decode([]byte{0x02}); err == nil {
+		t.Fatal("signed: want overflow error")
+	}
+
+	encode := func(value any, length int, options ...CodecOption) ([]byte, error) {
+		return NewFieldCodecConfig("bin", append([]CodecOption{WithEncode(), WithLength(length)}, options...)...).Encode(value)
+	}
+	if b, err := encode(uint64(math.MaxUint64), 8, WithUint()); err != nil || !bytes.Equal(b, maxBytes) {
+		t.Fatalf("uint64 max: got % X, %v", b, err)
+	}
+	if b, err := encode(-2, 3, WithBin()); err != nil || !bytes.Equal(b, []byte{0xFF, 0xFF, 0xFE}) {
+		t.Fatalf("3-byte signed: got % X, %v", b, err)
+	}
+	// 整数解释器接受编解码器支持的所有整数类型
+	for _, value := range []any{int64(110), uint32(110), int8(110), uint16(110), uint(110)} {
+		b, err := encode(value, 2, WithBin(), WithInteger(true, 2, -10))
+		if err != nil || !bytes.Equal(b, []byte{0x00, 0x3C}) {
+			t.Fatalf("%T: got % X, %v", value, b, err)
+		}
+	}
+	if b, err := encode(uint64(math.MaxUint64), 8, WithUint(), WithInteger(true, 1, 0)); err != nil || !bytes.Equal(b, maxBytes) {
+		t.Fatalf("uint64 max with integer: got % X, %v", b, err)
+	}
+	for _, c := range []struct {
+		value   any
+		length  int
+		options []CodecOption
+	}{
+		{256, 1, []CodecOption{WithUint()}},
+		{-1, 2, []CodecOption{WithUint()}},
+		{128, 1, []CodecOption{WithInt()}},
+		{-129, 1, []CodecOption{WithInt()}},
+		{uint64(math.MaxUint64), 8, []CodecOption{WithInt()}},
+	} {
+		if _, err := encode(c.value, c.length, c.options...); !errors.Is(err, ErrLength) {
+			t.Fatalf("encode %v into %d bytes: want ErrLength, got %v", c.value, c.length, err)
+		}
+	}
+}
+
+func TestCodecHEX(t *testing.T) {
+	mac := []byte{0x00, 0x1A, 0x2B, 0x3C, 0x4D, 0xEF}
+	cases := []struct {
+		options []CodecOption
+		want    string
+	}{
+		{[]CodecOption{WithHex()}, "001A2B3C4DEF"},
+		{[]CodecOption{WithHex(), WithHexLower()}, "001a2b3c4def"},
+		{[]CodecOption{WithHexWithOrder(binary.LittleEndian)}, "EF4D3C2B1A00"},
+		{[]CodecOption{WithHexWithOrder(binary.LittleEndian), WithHexLower()}, "ef4d3c2b1a00"},
+	}
+	for _, c := range cases {
+		decoded, err := NewFieldCodecConfig("hex", c.options...).Decode(mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Explained != c.want {
+			t.Fatalf("decoded %v, want %s", decoded.Explained, c.want)
+		}
+		options := append([]CodecOption{WithEncode(), WithLength(len(mac))}, c.options...)
+		encoded, err := NewFieldCodecConfig("hex", options...).Encode(c.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, mac) {
+			t.Fatalf("encoded % X, want % X", encoded, mac)
+		}
+	}
+	if _, err := NewFieldCodecConfig("hex", WithEncode(), WithHex(), WithLength(6)).Encode("001A2B"); !errors.Is(err, ErrLength) {
+		t.Fatalf("short value: want ErrLength, got %v", err)
+	}
+	if _, err := NewFieldCodecConfig("hex", WithEncode(), WithHex(), WithLength(1)).Encode("GG"); err == nil {
+		t.Fatal("want error for invalid hex")
+	}
+
+	// WithHex 与 WithBin、WithBcd 一样使用默认字节序
+	defer func(order binary.ByteOrder) { defaultOrder = order }(defaultOrder)
+	defaultOrder = binary.LittleEndian
+	for _, options := range [][]CodecOption{{WithHex()}, {WithHexLower()}} {
+		decoded, err := NewFieldCodecConfig("hex", append(options, WithLength(2))...).Decode([]byte{0x1A, 0x2B})
+		if err != nil || !strings.EqualFold(decoded.Explained.(string), "2B1A") {
+			t.Fatalf("default order: got %v, %v", decoded.Explained, err)
+		}
+	}
+}
+
+func TestCodecRaw(t *testing.T) {
+	uid := []byte{0x04, 0xA2, 0x3B, 0x5C}
+	decoded, err := NewFieldCodecConfig("uid", WithRaw(), WithLength(4)).Decode(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decoded.Explained.([]byte)
+	if !bytes.Equal(got, uid) {
+		t.Fatalf("decoded % X, want % X", got, uid)
+	}
+	// 解码结果不与输入共享内存
+	got[0] = 0xFF
+	if uid[0] != 0x04 {
+		t.Fatal("decoded bytes alias the input")
+	}
+
+	encoder := NewFieldCodecConfig("uid", WithEncode(), WithRaw(), WithLength(4))
+	if encoded, err := encoder.Encode(uid); err != nil || !bytes.Equal(encoded, uid) {
+		t.Fatalf("encoded % X, %v", encoded, err)
+	}
+	if _, err := encoder.Encode(uid[:3]); !errors.Is(err, ErrLength) {
+		t.Fatalf("short value: want ErrLength, got %v", err)
+	}
+}
+
+func TestCodecBCD(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []CodecOption
+		value   any
+		bytes   []byte
+		decoded any
+	}{
+		{"pile-code", []CodecOption{WithBcd(), WithLength(7), WithString()},
+			"00320100100001", []byte{0x00, 0x32, 0x01, 0x00, 0x10, 0x00, 0x01}, "00320100100001"},
+		{"odd-digits", []CodecOption{WithBcd(), WithLength(2), WithInteger(true, 1, 0)},
+			123, []byte{0x01, 0x23}, 123},
+		{"little-endian", []CodecOption{WithBcdWithOrder(binary.LittleEndian), WithLength(3), WithInteger(true, 1, 0)},
+			12345, []byte{0x45, 0x23, 0x01}, 12345},
+		{"multiple", []CodecOption{WithBcd(), WithLength(2), WithFloat(true, 0.5, 0)},
+			61.5, []byte{0x01, 0x23}, 61.5},
+		{"scale", []CodecOption{WithBcd(), WithBcdScale(2), WithLength(3), WithString()},
+			"12.5", []byte{0x00, 0x12, 0x50}, "12.50"},
+		{"scale-price", []CodecOption{WithBcd(), WithBcdScale(4), WithLength(4), WithFloat(true, 1, 0)},
+			0.8765, []byte{0x00, 0x00, 0x87, 0x65}, 0.8765},
+	}
+	for _, c := range cases {
+		encoded, err := NewFieldCodecConfig(c.name, append([]CodecOption{WithEncode()}, c.options...)...).Encode(c.value)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(encoded, c.bytes) {
+			t.Fatalf("%s: encoded % X, want % X", c.name, encoded, c.bytes)
+		}
+		decoded, err := NewFieldCodecConfig(c.name, c.options...).Decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if decoded.Explained != c.decoded {
+			t.Fatalf("%s: decoded %v (%T), want %v", c.name, decoded.Explained, decoded.Explained, c.decoded)
+		}
+	}
+
+	for _, c := range []struct {
+		value   any
+		options []CodecOption
+	}{
+		{"12a4", []CodecOption{WithBcd()}},
+		{"1.5", []CodecOption{WithBcd()}},
+		{"1.234", []CodecOption{WithBcd(), WithBcdScale(2)}},
+		{-1, []CodecOption{WithBcd()}},
+		{12345, []CodecOption{WithBcd()}},
+		{"100", []CodecOption{WithBcd(), WithBcdScale(2)}},
+		// 浮点数与字符串一样不做舍入
+		{12.5, []CodecOption{WithBcd()}},
+		{12.7, []CodecOption{WithBcd()}},
+		{1.234, []CodecOption{WithBcd(), WithBcdScale(2)}},
+	} {
+		_, err := NewFieldCodecConfig("bcd", append([]CodecOption{WithEncode(), WithLength(2)}, c.options...)...).Encode(c.value)
+		if err == nil {
+			t.Fatalf("encode %v: want error", c.value)
+		}
+	}
+	if _, err := NewFieldCodecConfig("bcd", WithEncode(), WithBcd(), WithLength(2)).Encode(12345); !errors.Is(err, ErrLength) {
+		t.Fatalf("overflow: want ErrLength, got %v", err)
+	}
+	bcd := &CodecBCD{order: binary.BigEndian, scale: 2}
+	for _, value := range []any{float32(12.34), 12.34, "12.34"} {
+		if b, err := bcd.Encode(value, 3); err != nil || !bytes.Equal(b, []byte{0x00, 0x12, 0x34}) {
+			t.Fatalf("encode %v (%T): got % X, %v", value, value, b, err)
+		}
+	}
+	for _, value := range []any{int8(12), int16(12), uint8(12), uint16(12)} {
+		if b, err := NewCodecBCD(binary.BigEndian).Encode(value, 1); err != nil || b[0] != 0x12 {
+			t.Fatalf("encode %T: got % X, %v", value, b, err)
+		}
+	}
+	if _, err := NewFieldCodecConfig("bcd", WithBcd(), WithBcdScale(2)).Decode([]byte{0x1A, 0x00}); err == nil {
+		t.Fatal("decode non-digit with scale: want error")
+	}
+}
+
+// TestGB18030Table 内嵌映射表与 tools/gen_gb18030.py 生成的结果一致
+func TestGB18030Table(t *testing.T) {
+	const want = "2ee3cecb0aa8a9e312cd2384c9b92f7fbd4646a337ed4e0811feef3e794569bb"
+	if sum := sha256.Sum256([]byte(gb18030Table)); hex.EncodeToString(sum[:]) != want {
+		t.Fatalf("charset_gb18030.bin checksum %x, want %s", sum, want)
+	}
+}
+
+func TestCodecString(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []CodecOption
+		value   string
+		bytes   []byte
+	}{
+		{"ascii", []CodecOption{WithAscii(), WithLength(6)},
+			"ABC", []byte{'A', 'B', 'C', 0x00, 0x00, 0x00}},
+		{"ascii-space-left", []CodecOption{WithAscii(), WithPadding(' ', PadLeft), WithLength(5)},
+			"42", []byte{' ', ' ', ' ', '4', '2'}},
+		{"utf8", []CodecOption{WithCharset(CharsetUTF8), WithLength(8)},
+			"充电", []byte{0xE5, 0x85, 0x85, 0xE7, 0x94, 0xB5, 0x00, 0x00}},
+		{"gbk-plate", []CodecOption{WithCharset(CharsetGBK), WithLength(10)},
+			"京A12345", []byte{0xBE, 0xA9, 'A', '1', '2', '3', '4', '5', 0x00, 0x00}},
+		{"gbk-space", []CodecOption{WithCharset(CharsetGBK), WithPadding(' ', PadRight), WithLength(8)},
+			"充电站", []byte{0xB3, 0xE4, 0xB5, 0xE7, 0xD5, 0xBE, ' ', ' '}},
+		{"gb18030", []CodecOption{WithCharset(CharsetGB18030), WithLength(10)},
+			"\u0080€𠀀", []byte{0x81, 0x30, 0x81, 0x30, 0xA2, 0xE3, 0x95, 0x32, 0x82, 0x36}},
+		{"utf16le", []CodecOption{WithCharset(CharsetUTF16LE), WithLength(6)},
+			"充电", []byte{0x45, 0x51, 0x35, 0x75, 0x00, 0x00}},
+		{"utf16be", []CodecOption{WithCharset(CharsetUTF16BE), WithPadding(' ', PadLeft), WithLength(6)},
+			"充电", []byte{0x00, ' ', 0x51, 0x45, 0x75, 0x35}},
+	}
+	for _, c := range cases {
+		encoded, err := NewFieldCodecConfig(c.name, append([]CodecOption{WithEncode()}, c.options...)...).Encode(c.value)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(encoded, c.bytes) {
+			t.Fatalf("%s: encoded % X, want % X", c.name, encoded, c.bytes)
+		}
+		decoded, err := NewFieldCodecConfig(c.name, c.options...).Decode(c.bytes)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if decoded.Explained != c.value {
+			t.Fatalf("%s: decoded %q, want %q", c.name, decoded.Explained, c.value)
+		}
+	}
+
+	if _, err := NewFieldCodecConfig("overflow", WithEncode(), WithCharset(CharsetGBK), WithLength(3)).Encode("充电"); !errors.Is(err, ErrLength) {
+		t.Fatalf("overflow: want ErrLength, got %v", err)
+	}
+	if _, err := NewFieldCodecConfig("gbk", WithEncode(), WithCharset(CharsetGBK), WithLength(8)).Encode("𠀀"); err == nil {
+		t.Fatal("want error for character outside GBK")
+	}
+	if _, err := NewFieldCodecConfig("ascii", WithEncode(), WithAscii(), WithLength(8)).Encode("充电"); err == nil {
+		t.Fatal("want error for non-ASCII string")
+	}
+	// 无法识别的字节解码为U+FFFD
+	if decoded, _ := NewFieldCodecConfig("gbk", WithCharset(CharsetGBK)).Decode([]byte{'A', 0xFF, 0x7F, 0xB3}); decoded.Explained != "A��" {
+		t.Fatalf("invalid GBK: got %q", decoded.Explained)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	enum := map[int]any{0: "A", 1: "B", 2: "C"}
+	decoder := NewFieldCodecConfig("e", WithBin(), WithLength(1), WithInteger(true, 1, 0), WithEnum("Other", enum))
+	if decoded, err := decoder.Decode([]byte{0x01}); err != nil || decoded.Explained != "B" {
+		t.Fatalf("decode: got %v, %v", decoded, err)
+	}
+	if decoded, err := decoder.Decode([]byte{0x07}); err != nil || decoded.Explained != "Other" {
+		t.Fatalf("decode unknown: got %v, %v", decoded, err)
+	}
+
+	// 编码时先查找标签对应的值, 再应用倍率和偏移量
+	encoder := NewFieldCodecConfig("e", WithEncode(), WithBin(), WithLength(1), WithInteger(true, 1, 10), WithEnum("Other", enum))
+	if encoded, err := encoder.Encode("B"); err != nil || !bytes.Equal(encoded, []byte{0xF7}) {
+		t.Fatalf("encode: got % X, %v", encoded, err)
+	}
+	// 枚举映射中的整数值原样编码
+	for _, code := range []any{1, uint64(1)} {
+		if encoded, err := encoder.Encode(code); err != nil || !bytes.Equal(encoded, []byte{0xF7}) {
+			t.Fatalf("encode %T value: got % X, %v", code, encoded, err)
+		}
+	}
+	for _, label := range []any{"D", "Other", 3} {
+		if _, err := encoder.Encode(label); !errors.Is(err, ErrUnknownEnum) {
+			t.Fatalf("encode %v: want ErrUnknownEnum, got %v", label, err)
+		}
+	}
+
+	strict := NewFieldCodecConfig("e", WithBin(), WithUint(), WithLength(1), WithStrictEnum(enum))
+	if decoded, err := strict.Decode([]byte{0x02}); err != nil || decoded.Explained != "C" {
+		t.Fatalf("strict decode: got %v, %v", decoded, err)
+	}
+	if _, err := strict.Decode([]byte{0x03}); !errors.Is(err, ErrUnknownEnum) {
+		t.Fatalf("strict decode unknown: want ErrUnknownEnum, got %v", err)
+	}
+}
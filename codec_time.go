@@ -0,0 +1,286 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// defaultBaseYear 两位年份对应的起始年份
+const defaultBaseYear = 2000
+
+// TimeFormat 时间字段的编码格式
+type TimeFormat int
+
+const (
+	TimeCP56Time2a TimeFormat = iota // IEC 60870-5 CP56Time2a, 7字节: 毫秒(2字节小端) 分 时 日 月 年
+	TimeBCD                          // BCD码 YYMMDDhhmmss(6字节) 或 YYYYMMDDhhmmss(7字节)
+	TimeUnix                         // Unix时间戳(秒), 4字节为无符号数, 8字节为有符号数
+	TimeUnixMilli                    // Unix时间戳(毫秒), 4字节为无符号数, 8字节为有符号数
+)
+
+func (f TimeFormat) String() string {
+	switch f {
+	case TimeCP56Time2a:
+		return "CP56Time2a"
+	case TimeBCD:
+		return "BCD"
+	case TimeUnix:
+		return "Unix"
+	case TimeUnixMilli:
+		return "UnixMilli"
+	default:
+		return fmt.Sprintf("TimeFormat(%d)", int(f))
+	}
+}
+
+// CodecTime 时间编解码器, 解码为 time.Time, 编码时接受 time.Time.
+// CP56Time2a 和BCD时间是设备的本地时间, 按 location 解释; Unix时间戳解码后转换到 location.
+// CP56Time2a 的无效标志(IV)置位时解码为零值 time.Time, 编码零值时置位无效标志;
+// 夏令时标志(SU)用于区分 location 夏令时结束时重复的一小时
+type CodecTime struct {
+	format   TimeFormat
+	order    binary.ByteOrder // Unix时间戳的字节序
+	location *time.Location
+	baseYear int // 两位年份表示 baseYear 到 baseYear+99
+}
+
+// NewCodecTime 创建时间编解码器, location 为nil时使用 time.Local
+func NewCodecTime(format TimeFormat, order binary.ByteOrder, location *time.Location) *CodecTime {
+	if location == nil {
+		location = time.Local
+	}
+	return &CodecTime{format: format, order: order, location: location, baseYear: defaultBaseYear}
+}
+
+func (c *CodecTime) Configure() {
+	// 初始化操作（如果需要）
+}
+
+// defaultLength 时间格式的默认字段长度
+func (c *CodecTime) defaultLength() int {
+	switch c.format {
+	case TimeCP56Time2a:
+		return 7
+	case TimeBCD:
+		return 6
+	case TimeUnixMilli:
+		return 8
+	default:
+		return 4
+	}
+}
+
+func (c *CodecTime) Encode(data any, byteLength int) ([]byte, error) {
+	t, ok := data.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("unsupported data type for %s time encoding: %T", c.format, data)
+	}
+	switch c.format {
+	case TimeCP56Time2a:
+		if byteLength != 7 {
+			return nil, fmt.Errorf("CP56Time2a needs 7 bytes, got %d: %w", byteLength, ErrLength)
+		}
+		return c.encodeCP56(t)
+	case TimeBCD:
+		return c.encodeBCD(t, byteLength)
+	case TimeUnix, TimeUnixMilli:
+		return c.encodeUnix(t, byteLength)
+	default:
+		return nil, fmt.Errorf("unsupported time format: %s", c.format)
+	}
+}
+
+func (c *CodecTime) Decode(data []byte) (any, error) {
+	switch c.format {
+	case TimeCP56Time2a:
+		return c.decodeCP56(data)
+	case TimeBCD:
+		return c.decodeBCD(data)
+	case TimeUnix, TimeUnixMilli:
+		return c.decodeUnix(data)
+	default:
+		return nil, fmt.Errorf("unsupported time format: %s", c.format)
+	}
+}
+
+// twoDigitYear 将年份转换为相对 baseYear 的两位年份
+func (c *CodecTime) twoDigitYear(year int) (int, error) {
+	if year < c.baseYear || year > c.baseYear+99 {
+		return 0, fmt.Errorf("year %d out of range %d-%d: %w", year, c.baseYear, c.baseYear+99, ErrLength)
+	}
+	return year - c.baseYear, nil
+}
+
+// date 按 location 构造时间, 并检查各字段是否在合法范围内(如2月30日)
+func (c *CodecTime) date(year, month, day, hour, minute, sec, nsec int) (time.Time, error) {
+	t := time.Date(year, time.Month(month), day, hour, minute, sec, nsec, c.location)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day ||
+		t.Hour() != hour || t.Minute() != minute || t.Second() != sec {
+		return time.Time{}, fmt.Errorf("invalid time %04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, sec)
+	}
+	return t, nil
+}
+
+func (c *CodecTime) decodeCP56(b []byte) (time.Time, error) {
+	if len(b) != 7 {
+		return time.Time{}, fmt.Errorf("CP56Time2a needs 7 bytes, got %d", len(b))
+	}
+	if b[2]&0x80 != 0 {
+		// 无效标志
+		return time.Time{}, nil
+	}
+	ms := int(binary.LittleEndian.Uint16(b[0:2]))
+	if ms > 59999 {
+		return time.Time{}, fmt.Errorf("invalid CP56Time2a milliseconds %d", ms)
+	}
+	t, err := c.date(c.baseYear+int(b[6]&0x7F), int(b[5]&0x0F), int(b[4]&0x1F),
+		int(b[3]&0x1F), int(b[2]&0x3F), ms/1000, ms%1000*int(time.Millisecond))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// 夏令时结束时本地时间重复一小时, 按夏令时标志选择其中一次
+	if summer := b[3]&0x80 != 0; summer != t.IsDST() {
+		for _, alt := range []time.Time{t.Add(time.Hour), t.Add(-time.Hour)} {
+			if alt.Hour() == t.Hour() && alt.Minute() == t.Minute() && alt.IsDST() == summer {
+				return alt, nil
+			}
+		}
+	}
+	return t, nil
+}
+
+func (c *CodecTime) encodeCP56(t time.Time) ([]byte, error) {
+	b := make([]byte, 7)
+	if t.IsZero() {
+		b[2] = 0x80
+		return b, nil
+	}
+	t = t.In(c.location)
+	year, err := c.twoDigitYear(t.Year())
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint16(b[0:2], uint16(t.Second()*1000+t.Nanosecond()/int(time.Millisecond)))
+	b[2] = byte(t.Minute())
+	b[3] = byte(t.Hour())
+	if t.IsDST() {
+		b[3] |= 0x80
+	}
+	// 星期: 1-7 表示周一到周日
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	b[4] = byte(t.Day()) | byte(weekday)<<5
+	b[5] = byte(t.Month())
+	b[6] = byte(year)
+	return b, nil
+}
+
+// bcdDigits 解码一个字节的两位BCD数字
+func bcdDigits(b byte) (int, error) {
+	hi, lo := b>>4, b&0x0F
+	if hi > 9 || lo > 9 {
+		return 0, fmt.Errorf("invalid BCD byte 0x%02X", b)
+	}
+	return int(hi)*10 + int(lo), nil
+}
+
+func (c *CodecTime) decodeBCD(b []byte) (time.Time, error) {
+	if len(b) != 6 && len(b) != 7 {
+		return time.Time{}, fmt.Errorf("BCD time needs 6 or 7 bytes, got %d", len(b))
+	}
+	digits := make([]int, len(b))
+	for i := range b {
+		d, err := bcdDigits(b[i])
+		if err != nil {
+			return time.Time{}, err
+		}
+		digits[i] = d
+	}
+	year := c.baseYear + digits[0]
+	if len(b) == 7 {
+		year = digits[0]*100 + digits[1]
+		digits = digits[1:]
+	}
+	return c.date(year, digits[1], digits[2], digits[3], digits[4], digits[5], 0)
+}
+
+func (c *CodecTime) encodeBCD(t time.Time, byteLength int) ([]byte, error) {
+	t = t.In(c.location)
+	var b []byte
+	switch byteLength {
+	case 6:
+		year, err := c.twoDigitYear(t.Year())
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, byte(year))
+	case 7:
+		if t.Year() < 0 || t.Year() > 9999 {
+			return nil, fmt.Errorf("year %d out of range 0-9999: %w", t.Year(), ErrLength)
+		}
+		b = append(b, byte(t.Year()/100), byte(t.Year()%100))
+	default:
+		return nil, fmt.Errorf("BCD time needs 6 or 7 bytes, got %d: %w", byteLength, ErrLength)
+	}
+	b = append(b, byte(t.Month()), byte(t.Day()), byte(t.Hour()), byte(t.Minute()), byte(t.Second()))
+	for i, v := range b {
+		b[i] = v/10<<4 | v%10
+	}
+	return b, nil
+}
+
+func (c *CodecTime) decodeUnix(b []byte) (time.Time, error) {
+	var n int64
+	switch len(b) {
+	case 4:
+		n = int64(c.order.Uint32(b))
+	case 8:
+		n = int64(c.order.Uint64(b))
+	default:
+		return time.Time{}, fmt.Errorf("unix time needs 4 or 8 bytes, got %d", len(b))
+	}
+	if c.format == TimeUnixMilli {
+		return time.UnixMilli(n).In(c.location), nil
+	}
+	return time.Unix(n, 0).In(c.location), nil
+}
+
+func (c *CodecTime) encodeUnix(t time.Time, byteLength int) ([]byte, error) {
+	n := t.Unix()
+	if c.format == TimeUnixMilli {
+		n = t.UnixMilli()
+	}
+	switch byteLength {
+	case 4:
+		if n < 0 || n > math.MaxUint32 {
+			return nil, fmt.Errorf("unix time %d overflows 4 bytes: %w", n, ErrLength)
+		}
+		b := make([]byte, 4)
+		c.order.PutUint32(b, uint32(n))
+		return b, nil
+	case 8:
+		b := make([]byte, 8)
+		c.order.PutUint64(b, uint64(n))
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unix time needs 4 or 8 bytes, got %d: %w", byteLength, ErrLength)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/longan55/Rules-over-TCP/fake"
+)
+
+func TestCodecTime(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	when := time.Date(2024, time.March, 5, 14, 7, 9, 123*int(time.Millisecond), shanghai)
+	cases := []struct {
+		name    string
+		options []CodecOption
+		bytes   []byte
+		want    time.Time
+	}{
+		// 2024-03-05是周二
+		{"cp56", []CodecOption{WithCP56Time2a(), WithTimeZone(shanghai)},
+			[]byte{0xA3, 0x23, 0x07, 0x0E, 0x45, 0x03, 0x18}, when},
+		{"bcd", []CodecOption{WithBCDTime(), WithTimeZone(shanghai)},
+			[]byte{0x24, 0x03, 0x05, 0x14, 0x07, 0x09}, when.Truncate(time.Second)},
+		{"bcd4", []CodecOption{WithBCDTime(), WithLength(7), WithTimeZone(shanghai)},
+			[]byte{0x20, 0x24, 0x03, 0x05, 0x14, 0x07, 0x09}, when.Truncate(time.Second)},
+		{"unix", []CodecOption{WithUnixTime(TimeUnix, binary.BigEndian), WithTimeZone(shanghai)},
+			binary.BigEndian.AppendUint32(nil, uint32(when.Unix())), when.Truncate(time.Second)},
+		{"unix-milli", []CodecOption{WithUnixTime(TimeUnixMilli, binary.LittleEndian), WithTimeZone(shanghai)},
+			binary.LittleEndian.AppendUint64(nil, uint64(when.UnixMilli())), when},
+		{"unix-milli-4", []CodecOption{WithUnixTime(TimeUnixMilli, binary.BigEndian), WithLength(4)},
+			[]byte{0x00, 0x00, 0x30, 0x39}, time.UnixMilli(12345)},
+	}
+	for _, c := range cases {
+		decoded, err := NewFieldCodecConfig(c.name, c.options...).Decode(c.bytes)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got, ok := decoded.Explained.(time.Time)
+		if !ok || !got.Equal(c.want) {
+			t.Fatalf("%s: decoded %v, want %v", c.name, decoded.Explained, c.want)
+		}
+		// 编码时先转换到配置的时区
+		encoded, err := NewFieldCodecConfig(c.name, append([]CodecOption{WithEncode()}, c.options...)...).Encode(c.want.UTC())
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(encoded, c.bytes) {
+			t.Fatalf("%s: encoded % X, want % X", c.name, encoded, c.bytes)
+		}
+	}
+
+	// 无效标志与零值互相转换
+	invalid, err := NewFieldCodecConfig("iv", WithCP56Time2a()).Decode([]byte{0xA3, 0x23, 0x87, 0x0E, 0x45, 0x03, 0x18})
+	if err != nil || !invalid.Explained.(time.Time).IsZero() {
+		t.Fatalf("invalid CP56Time2a: got %v, %v", invalid.Explained, err)
+	}
+	if b, _ := NewFieldCodecConfig("iv", WithEncode(), WithCP56Time2a()).Encode(time.Time{}); b[2]&0x80 == 0 {
+		t.Fatalf("zero time: want invalid flag, got % X", b)
+	}
+
+	// 起始年份
+	century, err := NewFieldCodecConfig("base", WithCP56Time2a(), WithTimeZone(time.UTC), WithBaseYear(1900)).Decode([]byte{0, 0, 0, 0, 0x01, 0x01, 0x63})
+	if err != nil || century.Explained.(time.Time).Year() != 1999 {
+		t.Fatalf("base year: got %v, %v", century.Explained, err)
+	}
+	if _, err := NewFieldCodecConfig("range", WithEncode(), WithCP56Time2a()).Encode(time.Date(2100, 1, 1, 0, 0, 0, 0, time.Local)); !errors.Is(err, ErrLength) {
+		t.Fatalf("year out of range: want ErrLength, got %v", err)
+	}
+
+	for name, b := range map[string][]byte{
+		"cp56-day":   {0, 0, 0, 0, 0x1E, 0x02, 0x18}, // 2月30日
+		"cp56-milli": {0x60, 0xEA, 0, 0, 0x01, 0x01, 0x18},
+		"bcd-digit":  {0x24, 0x0A, 0x05, 0x14, 0x07, 0x09},
+	} {
+		format := WithCP56Time2a()
+		if name == "bcd-digit" {
+			format = WithBCDTime()
+		}
+		if _, err := NewFieldCodecConfig(name, format).Decode(b); err == nil {
+			t.Fatalf("%s: want error", name)
+		}
+	}
+}
+
+func TestCodecTime_SummerTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 2024-10-27 02:30 在柏林出现两次, 夏令时标志区分先后
+	first := time.Date(2024, time.October, 27, 0, 30, 0, 0, time.UTC).In(berlin)
+	second := first.Add(time.Hour)
+	for _, want := range []time.Time{first, second} {
+		b, err := NewFieldCodecConfig("su", WithEncode(), WithCP56Time2a(), WithTimeZone(berlin)).Encode(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := NewFieldCodecConfig("su", WithCP56Time2a(), WithTimeZone(berlin)).Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := decoded.Explained.(time.Time); !got.Equal(want) {
+			t.Fatalf("summer flag %v: decoded %v, want %v", b[3]&0x80 != 0, got, want)
+		}
+	}
+}
+
+func TestCodecTime_AddField(t *testing.T) {
+	var got time.Time
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			got = hc.Parsed["time"].Explained.(time.Time)
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("time", WithBCDTime(), WithTimeZone(time.UTC))
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := fake.NewFakeConn()
+	conn.SetData(testFrame(0x03, []byte{0x25, 0x12, 0x31, 0x23, 0x59, 0x58}))
+	protocol.Serve(context.Background(), conn)
+	if want := time.Date(2025, time.December, 31, 23, 59, 58, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,356 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalScale Decimal 支持的最大小数位数, 10^18 是int64能表示的最大10的幂
+const maxDecimalScale = 18
+
+// ErrDecimalOverflow 定点小数超出int64尾数的表示范围
+var ErrDecimalOverflow = errors.New("定点小数溢出")
+
+// pow10 10的0到18次幂
+var pow10 = func() (p [maxDecimalScale + 1]int64) {
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
+	}
+	return
+}()
+
+// Decimal 定点小数, 值为 mantissa × 10^-scale, 用于电价、电量、金额等需要精确计算的字段.
+// 零值表示0. 四则运算超出int64尾数范围时panic
+type Decimal struct {
+	mantissa int64
+	scale    int
+}
+
+// NewDecimal 创建值为 mantissa × 10^-scale 的定点小数, scale 的范围是0-18
+func NewDecimal(mantissa int64, scale int) Decimal {
+	if scale < 0 || scale > maxDecimalScale {
+		panic(fmt.Sprintf("decimal scale %d out of range 0-%d", scale, maxDecimalScale))
+	}
+	return Decimal{mantissa: mantissa, scale: scale}
+}
+
+// ParseDecimal 解析十进制小数字符串(如"-12.3400"), 保留其小数位数
+func ParseDecimal(s string) (Decimal, error) {
+	digits, negative := s, false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		digits, negative = s[1:], s[0] == '-'
+	}
+	intPart, fracPart, hasPoint := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" || hasPoint && fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(fracPart) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d decimal places", s, maxDecimalScale)
+	}
+	m, err := strconv.ParseUint(intPart+fracPart, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Decimal{}, fmt.Errorf("decimal %q: %w", s, ErrDecimalOverflow)
+		}
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if m > math.MaxInt64 && !(negative && m == math.MaxInt64+1) {
+		return Decimal{}, fmt.Errorf("decimal %q: %w", s, ErrDecimalOverflow)
+	}
+	mantissa := int64(m)
+	if negative {
+		mantissa = -mantissa
+	}
+	return Decimal{mantissa: mantissa, scale: len(fracPart)}, nil
+}
+
+// Mantissa 返回尾数
+func (d Decimal) Mantissa() int64 {
+	return d.mantissa
+}
+
+// Scale 返回小数位数
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// String 返回保留全部小数位的十进制表示, 如"0.0050"
+func (d Decimal) String() string {
+	s := strconv.FormatUint(absUint64(d.mantissa), 10)
+	if d.scale > 0 {
+		if len(s) <= d.scale {
+			s = strings.Repeat("0", d.scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+	}
+	if d.mantissa < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// MarshalJSON 编码为JSON数字, 保留全部小数位
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON 从JSON数字或字符串解码
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		return nil
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Float64 返回最接近的浮点数, 仅用于显示或统计
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IsZero 返回是否为0
+func (d Decimal) IsZero() bool {
+	return d.mantissa == 0
+}
+
+// Neg 返回相反数
+func (d Decimal) Neg() Decimal {
+	if d.mantissa == math.MinInt64 {
+		panic(ErrDecimalOverflow)
+	}
+	return Decimal{mantissa: -d.mantissa, scale: d.scale}
+}
+
+// Cmp 比较大小, d 小于、等于、大于 o 时分别返回-1、0、1
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, ok := align(d, o)
+	if !ok {
+		// 对齐溢出时小数位数少的一方绝对值更大
+		if d.scale < o.scale {
+			return sign(d.mantissa)
+		}
+		return -sign(o.mantissa)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Add 返回 d+o, 小数位数取两者中较大的
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b, ok := align(d, o)
+	sum := a + b
+	if !ok || (a >= 0) == (b >= 0) && (sum >= 0) != (a >= 0) {
+		panic(ErrDecimalOverflow)
+	}
+	return Decimal{mantissa: sum, scale: max(d.scale, o.scale)}
+}
+
+// Sub 返回 d-o, 小数位数取两者中较大的
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Mul 返回 d×o, 小数位数为两者之和; 如电价×电量后再用 Round 保留到分
+func (d Decimal) Mul(o Decimal) Decimal {
+	scale := d.scale + o.scale
+	hi, lo := bits.Mul64(absUint64(d.mantissa), absUint64(o.mantissa))
+	negative := (d.mantissa < 0) != (o.mantissa < 0)
+	if scale > maxDecimalScale || hi != 0 || lo > math.MaxInt64 && !(negative && lo == math.MaxInt64+1) {
+		panic(ErrDecimalOverflow)
+	}
+	m := int64(lo)
+	if negative {
+		m = -m
+	}
+	return Decimal{mantissa: m, scale: scale}
+}
+
+// Round 按四舍五入(远离0)保留 scale 位小数, scale 大于当前位数时补0
+func (d Decimal) Round(scale int) Decimal {
+	if scale < 0 || scale > maxDecimalScale {
+		panic(fmt.Sprintf("decimal scale %d out of range 0-%d", scale, maxDecimalScale))
+	}
+	if scale >= d.scale {
+		m, ok := mulPow10(d.mantissa, scale-d.scale)
+		if !ok {
+			panic(ErrDecimalOverflow)
+		}
+		return Decimal{mantissa: m, scale: scale}
+	}
+	div := pow10[d.scale-scale]
+	q, r := d.mantissa/div, d.mantissa%div
+	if absUint64(r)*2 >= uint64(div) {
+		q += int64(sign(d.mantissa))
+	}
+	return Decimal{mantissa: q, scale: scale}
+}
+
+// rescale 不丢失精度地转换为 scale 位小数, 需要舍入或溢出时返回错误
+func (d Decimal) rescale(scale int) (int64, error) {
+	if scale >= d.scale {
+		m, ok := mulPow10(d.mantissa, scale-d.scale)
+		if !ok {
+			return 0, fmt.Errorf("decimal %s with %d decimal places: %w", d, scale, ErrDecimalOverflow)
+		}
+		return m, nil
+	}
+	div := pow10[d.scale-scale]
+	if d.mantissa%div != 0 {
+		return 0, fmt.Errorf("decimal %s has more than %d decimal places", d, scale)
+	}
+	return d.mantissa / div, nil
+}
+
+// align 将两个数的尾数对齐到较大的小数位数
+func align(a, b Decimal) (int64, int64, bool) {
+	scale := max(a.scale, b.scale)
+	x, ok1 := mulPow10(a.mantissa, scale-a.scale)
+	y, ok2 := mulPow10(b.mantissa, scale-b.scale)
+	return x, y, ok1 && ok2
+}
+
+// mulPow10 计算 m×10^n, 溢出时返回false
+func mulPow10(m int64, n int) (int64, bool) {
+	if n == 0 || m == 0 {
+		return m, true
+	}
+	if n > maxDecimalScale {
+		return 0, false
+	}
+	p := pow10[n]
+	if m > math.MaxInt64/p || m < math.MinInt64/p {
+		return 0, false
+	}
+	return m * p, true
+}
+
+func absUint64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+func sign(n int64) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// dtDecimal 定点小数解释器, 字段的整数值是以 10^-scale 为单位的尾数
+type dtDecimal struct {
+	scale int
+}
+
+var (
+	_ CodecOption      = (*dtDecimal)(nil)
+	_ DataTyper        = (*dtDecimal)(nil)
+	_ checkedDataTyper = (*dtDecimal)(nil)
+)
+
+func (t *dtDecimal) Apply(config *FieldCodecConfig) {
+	config.dataTyper = t
+}
+
+func (t *dtDecimal) Explain(data any) any {
+	d, err := t.explain(data)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (t *dtDecimal) UnExplain(data any) any {
+	m, err := t.unexplain(data)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// explain 将BIN码的整数或BCD码的数字串解释为定点小数; 带小数点的数字串(如设置了BCD小数位数)按其数值解释,
+// 并转换为 scale 位小数, 与编码时一致, 需要舍入时返回错误
+func (t *dtDecimal) explain(data any) (any, error) {
+	switch v := data.(type) {
+	case int:
+		return Decimal{mantissa: int64(v), scale: t.scale}, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("decimal mantissa %d: %w", v, ErrDecimalOverflow)
+		}
+		return Decimal{mantissa: int64(v), scale: t.scale}, nil
+	case string:
+		d, err := ParseDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(v, ".") {
+			m, err := d.rescale(t.scale)
+			if err != nil {
+				return nil, err
+			}
+			return Decimal{mantissa: m, scale: t.scale}, nil
+		}
+		return Decimal{mantissa: d.mantissa, scale: t.scale}, nil
+	default:
+		return nil, fmt.Errorf("unsupported data type for decimal: %T", data)
+	}
+}
+
+// unexplain 将定点小数转换回尾数, 小数位数超过 scale 时返回错误而不是舍入
+func (t *dtDecimal) unexplain(data any) (any, error) {
+	var d Decimal
+	switch v := data.(type) {
+	case Decimal:
+		d = v
+	case string:
+		parsed, err := ParseDecimal(v)
+		if err != nil {
+			return nil, err
+		}
+		d = parsed
+	case int:
+		d = Decimal{mantissa: int64(v)}
+	case int64:
+		d = Decimal{mantissa: v}
+	default:
+		return nil, fmt.Errorf("unsupported data type for decimal encoding: %T", data)
+	}
+	return d.rescale(t.scale)
+}
@@ -0,0 +1,147 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	for s, want := range map[string]string{
+		"1234.5678":            "1234.5678",
+		"-0.0050":              "-0.0050",
+		"+7":                   "7",
+		".5":                   "0.5",
+		"-9223372036854775808": "-9223372036854775808",
+	} {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if d.String() != want {
+			t.Fatalf("%s: got %s, want %s", s, d, want)
+		}
+	}
+	for _, s := range []string{"", "-", "1.", "1e3", "--1", "1.2.3"} {
+		if _, err := ParseDecimal(s); err == nil {
+			t.Fatalf("%q: want error", s)
+		}
+	}
+	if _, err := ParseDecimal("9223372036854775808"); !errors.Is(err, ErrDecimalOverflow) {
+		t.Fatalf("want ErrDecimalOverflow, got %v", err)
+	}
+
+	price := NewDecimal(12345, 4)  // 1.2345元/度
+	energy := NewDecimal(10050, 3) // 10.050度
+	if got := price.Mul(energy).String(); got != "12.4067250" {
+		t.Fatalf("mul: got %s", got)
+	}
+	if got := price.Mul(energy).Round(2).String(); got != "12.41" {
+		t.Fatalf("round: got %s", got)
+	}
+	if got := NewDecimal(-125, 2).Round(1).String(); got != "-1.3" {
+		t.Fatalf("round half away from zero: got %s", got)
+	}
+	if got := price.Add(energy).String(); got != "11.2845" {
+		t.Fatalf("add: got %s", got)
+	}
+	if got := price.Sub(energy).String(); got != "-8.8155" {
+		t.Fatalf("sub: got %s", got)
+	}
+	if price.Cmp(energy) != -1 || energy.Cmp(price) != 1 || NewDecimal(10, 1).Cmp(NewDecimal(1, 0)) != 0 {
+		t.Fatal("cmp: unexpected result")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrDecimalOverflow {
+				t.Fatalf("want overflow panic, got %v", r)
+			}
+		}()
+		NewDecimal(1<<62, 0).Add(NewDecimal(1<<62, 0))
+	}()
+
+	var v struct {
+		Price Decimal `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 0.0001}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"price":0.0001}` {
+		t.Fatalf("marshal: got %s", b)
+	}
+}
+
+func TestDecimalField(t *testing.T) {
+	// 0.0001 × 12345678 不经过浮点数
+	decoded, err := NewFieldCodecConfig("price", WithBin(), WithLength(4), WithDecimal(4)).Decode([]byte{0x00, 0xBC, 0x61, 0x4E})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := decoded.Explained.(Decimal); d.String() != "1234.5678" {
+		t.Fatalf("BIN: got %s", d)
+	}
+	if d := Bin2Decimal(binary.BigEndian, []byte{0xFF, 0x85}, 2); d.String() != "-1.23" {
+		t.Fatalf("Bin2Decimal: got %s", d)
+	}
+
+	cases := []struct {
+		name    string
+		options []CodecOption
+		value   any
+		bytes   []byte
+	}{
+		{"bin", []CodecOption{WithBin(), WithLength(4), WithDecimal(4)}, NewDecimal(12345678, 4), []byte{0x00, 0xBC, 0x61, 0x4E}},
+		{"bin-negative", []CodecOption{WithBin(), WithLength(2), WithDecimal(2)}, "-1.23", []byte{0xFF, 0x85}},
+		{"bin-rescale", []CodecOption{WithBinWithOrder(binary.LittleEndian), WithUint(), WithLength(2), WithDecimal(3)}, "1.5", []byte{0xDC, 0x05}},
+		{"bcd", []CodecOption{WithBcd(), WithLength(4), WithDecimal(5)}, "0.87654", []byte{0x00, 0x08, 0x76, 0x54}},
+		// BCD小数位数与定点小数同时设置时小数位数只应用一次
+		{"bcd-scale", []CodecOption{WithBcd(), WithBcdScale(2), WithLength(3), WithDecimal(2)}, "123.45", []byte{0x01, 0x23, 0x45}},
+		{"bcd-scale-4", []CodecOption{WithBcd(), WithBcdScale(4), WithLength(3), WithDecimal(2)}, "1.23", []byte{0x01, 0x23, 0x00}},
+		{"bcd-scale-1", []CodecOption{WithBcd(), WithBcdScale(1), WithLength(3), WithDecimal(2)}, NewDecimal(123450, 2), []byte{0x01, 0x23, 0x45}},
+	}
+	for _, c := range cases {
+		encoded, err := NewFieldCodecConfig(c.name, append([]CodecOption{WithEncode()}, c.options...)...).Encode(c.value)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(encoded, c.bytes) {
+			t.Fatalf("%s: encoded % X, want % X", c.name, encoded, c.bytes)
+		}
+		decoded, err := NewFieldCodecConfig(c.name, c.options...).Decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		want, _ := c.value.(Decimal)
+		if s, ok := c.value.(string); ok {
+			want, _ = ParseDecimal(s)
+		}
+		if got := decoded.Explained.(Decimal); got.Cmp(want) != 0 {
+			t.Fatalf("%s: decoded %s, want %s", c.name, got, want)
+		}
+	}
+
+	// BCD小数位数多于定点小数时, 解码需要舍入的值返回错误, 与编码一致
+	if _, err := NewFieldCodecConfig("price", WithBcd(), WithBcdScale(4), WithLength(3), WithDecimal(2)).Decode([]byte{0x01, 0x23, 0x45}); err == nil {
+		t.Fatal("want error for decimal places beyond the scale")
+	}
+	// 小数位数超过字段时返回错误而不是舍入
+	if _, err := NewFieldCodecConfig("price", WithEncode(), WithBin(), WithLength(4), WithDecimal(4)).Encode("1.23456"); err == nil {
+		t.Fatal("want error for extra decimal places")
+	}
+}
@@ -0,0 +1,407 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var _ ProtocolDataUnitAccessor = (*decodeState)(nil)
+
+// decodeState 一个会话或一次 DecodeFrame 调用的解码上下文, 保存当前数据单元各元素的原始字节和解析值,
+// 元素通过它访问当前数据单元, 因此同一个协议可以同时服务多个连接
+type decodeState struct {
+	*ProtocolDataUnit
+	ctx     context.Context
+	session *Session // 为nil时只解码, 不调用处理函数
+	reader  *FrameReader
+	counts  uint64
+
+	sources    [][]byte
+	values     []any
+	receivedAt time.Time
+	frame      *Frame // 当前数据单元调用处理函数时创建的Frame
+
+	probe bool // 分帧时试探校验, 不记录指标
+
+	// 会话中上一个数据单元的序列号
+	lastSerial uint16
+	hasSerial  bool
+
+	// 异步处理模式下尚未完成的处理函数和第一个使会话停止的错误
+	pending  sync.WaitGroup
+	asyncMu  sync.Mutex
+	asyncErr error
+}
+
+func newDecodeState(ctx context.Context, pdu *ProtocolDataUnit, session *Session, reader *FrameReader) *decodeState {
+	return &decodeState{
+		ProtocolDataUnit: pdu,
+		ctx:              ctx,
+		session:          session,
+		reader:           reader,
+		sources:          make([][]byte, len(pdu.elements)),
+		values:           make([]any, len(pdu.elements)),
+	}
+}
+
+// Source 获取元素在当前数据单元中的原始字节
+func (st *decodeState) Source(element ProtocolElement) []byte {
+	return st.sources[element.GetIndex()]
+}
+
+// SetSource 设置元素在当前数据单元中的原始字节
+func (st *decodeState) SetSource(element ProtocolElement, src []byte) {
+	st.sources[element.GetIndex()] = src
+}
+
+// RealValue 获取元素在当前数据单元中解析后的值
+func (st *decodeState) RealValue(element ProtocolElement) any {
+	return st.values[element.GetIndex()]
+}
+
+// SetRealValue 设置元素在当前数据单元中解析后的值
+func (st *decodeState) SetRealValue(element ProtocolElement, value any) {
+	st.values[element.GetIndex()] = value
+}
+
+// Metrics 返回协议的指标, 分帧时试探校验返回nil
+func (st *decodeState) Metrics() *Metrics {
+	if st.probe {
+		return nil
+	}
+	return st.metrics
+}
+
+// Logger 返回当前会话的日志记录器, 只解码时返回协议的日志记录器
+func (st *decodeState) Logger() *slog.Logger {
+	if st.session != nil {
+		return st.session.Logger()
+	}
+	return st.logger
+}
+
+// RawFrame 返回当前数据单元已读取的全部原始字节
+func (st *decodeState) RawFrame() []byte {
+	return st.reader.Bytes()
+}
+
+// DoHandle 创建Frame并在会话中执行处理函数, 负载在处理函数链中解析, 解析错误同样经过中间件;
+// 只解码时直接解析负载并仅保留Frame
+func (st *decodeState) DoHandle(code FunctionCode, payload []byte) error {
+	// 负载可能指向会话复用的帧缓冲区, 复制后再解析, 使字段的原始字节也可以被持有
+	payload = bytes.Clone(payload)
+	st.frame = st.newFrame(code, payload, nil)
+	handler, ok := st.handlerMap[code]
+	if st.session == nil {
+		if !ok {
+			return nil
+		}
+		parsed, err := handler.Parse(payload)
+		if err != nil {
+			return err
+		}
+		st.frame.fields = parsed
+		return nil
+	}
+	if st.session.onFrame != nil {
+		// 客户端的Call应答不经过处理函数, 交出前先解析, 解析错误交给等待的Call;
+		// 没有Call等待时由处理函数链再次解析并报告错误
+		var err error
+		if ok {
+			var parsed map[string]ParsedData
+			if parsed, err = handler.Parse(payload); err == nil {
+				st.frame.fields = parsed
+			}
+		}
+		if st.session.onFrame(st.frame, err) {
+			return nil
+		}
+	}
+	hc := &HandlerContext{
+		Code:    code,
+		Payload: st.frame.payload,
+		Parsed:  st.frame.fields,
+		Frame:   st.frame,
+		Conn:    st.session.Conn(),
+		Session: st.session,
+		ctx:     st.ctx,
+	}
+	if st.dispatcher != nil {
+		return st.dispatch(hc)
+	}
+	return st.doHandle(st, hc)
+}
+
+// newFrame 根据当前数据单元创建Frame, 原始帧从帧缓冲区复制, payload须已由调用方复制
+func (st *decodeState) newFrame(code FunctionCode, payload []byte, fields map[string]ParsedData) *Frame {
+	raw := bytes.Clone(st.RawFrame())
+	frame := &Frame{
+		raw:        raw,
+		code:       code,
+		payload:    payload,
+		fields:     fields,
+		receivedAt: st.receivedAt,
+	}
+	offset := 0
+	for i, element := range st.elements {
+		n := len(st.sources[i])
+		if offset+n > len(raw) {
+			break
+		}
+		// 元素的原始字节指向复制后的帧
+		src := raw[offset : offset+n : offset+n]
+		offset += n
+		switch element.Type() {
+		case Payload:
+		case Checksum:
+			frame.checksum = src
+		default:
+			frame.headers = append(frame.headers, ElementValue{
+				Name:  element.GetName(),
+				Type:  element.Type(),
+				Raw:   src,
+				Value: st.values[i],
+			})
+		}
+	}
+	return frame
+}
+
+// functionCode 返回当前数据单元的功能码
+func (st *decodeState) functionCode() FunctionCode {
+	if element := st.GetElementByType(Function); element != nil {
+		code, _ := st.RealValue(element).(FunctionCode)
+		return code
+	}
+	return 0
+}
+
+// readFrame 读取一个完整的数据单元
+func (st *decodeState) readFrame() error {
+	st.reader.Reset()
+	clear(st.sources)
+	clear(st.values)
+	st.frame = nil
+	for _, element := range st.elements {
+		if err := element.Preprocess(st.reader, element, st); err != nil {
+			return err
+		}
+	}
+	st.receivedAt = time.Now()
+	return nil
+}
+
+// checkSerial 检查序列号相对同一会话的上一个数据单元递增; 按16位回绕比较, 相同或回退的序列号返回 ErrSerialNumber
+func (st *decodeState) checkSerial(element ProtocolElement) error {
+	sn, _ := st.RealValue(element).(int)
+	current := uint16(sn)
+	if st.hasSerial {
+		if d := current - st.lastSerial; d == 0 || d >= 0x8000 {
+			return newProtocolError(ErrSerialNumber, element, st, nil, st.Source(element),
+				fmt.Errorf("上一个序列号 %d, 收到 %d", st.lastSerial, current))
+		}
+	}
+	st.lastSerial, st.hasSerial = current, true
+	return nil
+}
+
+// dealFrame 校验并处理已读取的数据单元, 返回失败的元素和错误
+func (st *decodeState) dealFrame() (ProtocolElement, error) {
+	for _, element := range st.dealOrder {
+		if err := element.Deal(element, st); err != nil {
+			return element, err
+		}
+	}
+	return nil, nil
+}
+
+// serveFrame 读取并处理一个数据单元, 返回nil表示继续读取下一帧
+func (st *decodeState) serveFrame() error {
+	// 处理函数可能设置了设备标识, 每个数据单元重新获取日志记录器
+	if logger := st.session.Logger(); logger.Enabled(st.ctx, slog.LevelDebug) {
+		logger.Debug("数据单元解析开始", "count", st.counts)
+	}
+	if err := st.readFrame(); err != nil {
+		serveErr := readStopError(st.ctx, err)
+		if serveErr.Reason == StopEOF || serveErr.Reason == StopCanceled {
+			st.session.Logger().Debug("连接结束", "reason", serveErr.Reason, "err", err)
+		} else {
+			st.session.Logger().Warn("数据预处理失败", "reason", serveErr.Reason, "err", err)
+		}
+		return serveErr
+	}
+	if st.metrics != nil {
+		st.metrics.frameReceived(st.functionCode(), len(st.RawFrame()))
+	}
+	if element, err := st.dealFrame(); err != nil {
+		st.session.Logger().Warn("数据解析失败", "element", element.GetName(), "err", err)
+		if serveErr := st.handleFrameError(element, err); serveErr != nil {
+			return serveErr
+		}
+		// 起始符错误时帧长度取自垃圾数据, 从下一个起始符重新开始读取
+		if errors.Is(err, ErrBadPreamble) {
+			if err := st.reader.skipTo(st.elements[0].DefaultValue()); err != nil {
+				return readStopError(st.ctx, err)
+			}
+		}
+	}
+	if logger := st.session.Logger(); logger.Enabled(st.ctx, slog.LevelDebug) {
+		logger.Debug("数据单元解析完成", "count", st.counts)
+	}
+	st.counts++
+	return nil
+}
+
+// handleFrameError 按错误策略处理帧级错误, 返回nil表示继续读取下一帧
+func (st *decodeState) handleFrameError(element ProtocolElement, err error) error {
+	frame := st.frame
+	if frame == nil {
+		var payload []byte
+		if element := st.GetElementByType(Payload); element != nil {
+			payload, _ = st.RealValue(element).([]byte)
+		}
+		payload = bytes.Clone(payload)
+		frame = st.newFrame(st.functionCode(), payload, nil)
+	}
+	return st.applyErrorPolicy(&FrameError{
+		Class:   classifyFrameError(element, err),
+		Element: element,
+		Raw:     frame.raw,
+		Frame:   frame,
+		Err:     err,
+	})
+}
+
+// applyErrorPolicy 调用帧级错误回调并执行错误策略, 返回nil表示继续读取下一帧; 只使用不可变的Frame, 可在工作协程中调用
+func (st *decodeState) applyErrorPolicy(fe *FrameError) error {
+	if st.onFrameError != nil {
+		st.onFrameError(fe)
+	}
+	action := ActionEscalate
+	if st.errorPolicy != nil {
+		action = st.errorPolicy(fe)
+	}
+	switch action {
+	case ActionSkip:
+		st.metrics.resynced()
+		return nil
+	case ActionNack:
+		st.metrics.resynced()
+		if st.nack == nil {
+			return nil
+		}
+		if nack := st.nack(fe); len(nack) > 0 {
+			if err := st.session.write(nack); err != nil {
+				return readStopError(st.ctx, err)
+			}
+		}
+		return nil
+	case ActionClose:
+		st.session.Conn().Close()
+		return &ServeError{Reason: StopProtocol, Err: fe}
+	default:
+		return &ServeError{Reason: StopProtocol, Err: fe}
+	}
+}
+
+// DecodeFrame 解码内存中的一个完整数据单元: 校验起始符和校验码、解密负载, 功能码配置了处理函数时解析字段, 但不调用处理函数.
+// data必须恰好包含一个数据单元, 可用于日志回放、其他传输通道(如MQTT)中的帧和单元测试
+func (pdu *ProtocolDataUnit) DecodeFrame(data []byte) (*Frame, error) {
+	state := newDecodeState(context.Background(), pdu, nil, nil)
+	if err := state.readExact(data); err != nil {
+		return nil, err
+	}
+	if _, err := state.dealFrame(); err != nil {
+		return nil, err
+	}
+	if state.frame == nil {
+		// 协议中没有负载元素
+		state.frame = state.newFrame(state.functionCode(), nil, nil)
+	}
+	return state.frame, nil
+}
+
+// readExact 从data读取一个数据单元, data不完整或在数据单元之后还有数据时返回 ErrLengthMismatch
+func (st *decodeState) readExact(data []byte) error {
+	st.reader = NewFrameReaderBytes(data)
+	if err := st.readFrame(); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return &ProtocolError{
+				Kind:   ErrLengthMismatch,
+				Actual: bytes.Clone(data),
+				Offset: len(st.RawFrame()),
+				Err:    fmt.Errorf("数据单元不完整: %w", io.ErrUnexpectedEOF),
+			}
+		}
+		return err
+	}
+	if n := len(st.RawFrame()); n != len(data) {
+		return &ProtocolError{
+			Kind:   ErrLengthMismatch,
+			Actual: bytes.Clone(data[n:]),
+			Offset: n,
+			Err:    fmt.Errorf("数据单元之后还有%d字节", len(data)-n),
+		}
+	}
+	return nil
+}
+
+// Split 返回data开头第一个完整数据单元的长度, 数据不足时返回0和nil; 只做分帧, 不校验校验码.
+// data开头不是起始符时返回 ErrBadPreamble
+func (pdu *ProtocolDataUnit) Split(data []byte) (int, error) {
+	if err := pdu.checkPreamble(data); err != nil {
+		return 0, err
+	}
+	state := newDecodeState(context.Background(), pdu, nil, NewFrameReaderBytes(data))
+	if err := state.readFrame(); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, state.checkFrameSize()
+		}
+		return 0, err
+	}
+	return len(state.RawFrame()), nil
+}
+
+// checkFrameSize 检查不完整数据单元的长度元素, 声明的长度超过最大帧长时返回 ErrLengthMismatch,
+// 避免等待永远不会到达的数据
+func (st *decodeState) checkFrameSize() error {
+	element := st.GetElementByType(Length)
+	if element == nil {
+		return nil
+	}
+	if length, ok := st.RealValue(element).(int); ok && length > st.maxFrameSize {
+		return newProtocolError(ErrLengthMismatch, element, st, nil, st.Source(element),
+			fmt.Errorf("帧长度%d超过最大帧长%d", length, st.maxFrameSize))
+	}
+	return nil
+}
+
+// checkPreamble 检查data开头(可能不完整)是否与起始符一致
+func (pdu *ProtocolDataUnit) checkPreamble(data []byte) error {
+	preamble := pdu.elements[0].DefaultValue()
+	n := min(len(data), len(preamble))
+	if !bytes.Equal(data[:n], preamble[:n]) {
+		return newProtocolError(ErrBadPreamble, pdu.elements[0], nil, preamble, data[:n], nil)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecodeFrame(t *testing.T) {
+	called := false
+	protocol, err := newTestBuilder().
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			called = true
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	frame, err := protocol.DecodeFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Code() != 0x03 || !bytes.Equal(frame.Payload(), []byte("0123")) || !bytes.Equal(frame.Raw(), data) {
+		t.Fatalf("unexpected frame: %+v", frame)
+	}
+	if called {
+		t.Fatal("DecodeFrame should not invoke handlers")
+	}
+
+	if _, err := protocol.DecodeFrame(data[:7]); !errors.Is(err, ErrLengthMismatch) {
+		t.Fatalf("want ErrLengthMismatch for short frame, got %v", err)
+	}
+	if _, err := protocol.DecodeFrame(append(bytes.Clone(data), 0x68)); !errors.Is(err, ErrLengthMismatch) {
+		t.Fatalf("want ErrLengthMismatch for trailing bytes, got %v", err)
+	}
+	bad := bytes.Clone(data)
+	bad[9] = 0x00
+	if _, err := protocol.DecodeFrame(bad); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("want ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame1 := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	frame2 := []byte{0x68, 0x0F, 0x00, 0x01, 0x7F, 0xFF, 0xFF, 0xFF, 0x80, 0x00, 0x00, 0x00, 0x12, 0x34, 0x12, 0x34, 0x01, 0x1a, 0x40}
+	stream := append(bytes.Clone(frame1), frame2...)
+
+	n, err := protocol.Split(stream)
+	if err != nil || n != len(frame1) {
+		t.Fatalf("want %d, got %d, %v", len(frame1), n, err)
+	}
+	n, err = protocol.Split(stream[n:])
+	if err != nil || n != len(frame2) {
+		t.Fatalf("want %d, got %d, %v", len(frame2), n, err)
+	}
+	for i := range len(frame1) {
+		if n, err := protocol.Split(frame1[:i]); n != 0 || err != nil {
+			t.Fatalf("partial frame of %d bytes: want 0, nil, got %d, %v", i, n, err)
+		}
+	}
+	if _, err := protocol.Split([]byte{0x00, 0x68}); !errors.Is(err, ErrBadPreamble) {
+		t.Fatalf("want ErrBadPreamble, got %v", err)
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame1 := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	frame2 := []byte{0x68, 0x0F, 0x00, 0x01, 0x7F, 0xFF, 0xFF, 0xFF, 0x80, 0x00, 0x00, 0x00, 0x12, 0x34, 0x12, 0x34, 0x01, 0x1a, 0x40}
+
+	var stream []byte
+	stream = append(stream, 0x00, 0x11, 0x22)                   // 帧首之前的垃圾
+	stream = append(stream, frame1...)                          // 完整帧
+	stream = append(stream, 0x68, 0x02, 0x00, 0x03, 0xAA, 0xBB) // 含起始符但校验码错误的垃圾
+	stream = append(stream, frame2...)                          // 完整帧
+	stream = append(stream, frame1[:6]...)                      // 末尾不完整的帧
+
+	scanner := bufio.NewScanner(&oneByteReader{data: stream})
+	scanner.Split(protocol.SplitFunc())
+	var frames [][]byte
+	for scanner.Scan() {
+		frames = append(frames, bytes.Clone(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || !bytes.Equal(frames[0], frame1) || !bytes.Equal(frames[1], frame2) {
+		t.Fatalf("unexpected frames: % X", frames)
+	}
+}
+
+func TestSplitFunc_Garbage(t *testing.T) {
+	metrics := NewMetrics()
+	protocol, err := newTestBuilder().SetMetrics(metrics).SetMaxFrameSize(16).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	split := protocol.SplitFunc()
+
+	// 声明长度超过最大帧长的起始符被跳过, 不等待更多数据
+	data := append([]byte{0x68, 0xF0, 0x00}, frame...)
+	advance, token, err := split(data, false)
+	if err != nil || advance != len(data) || !bytes.Equal(token, frame) {
+		t.Fatalf("huge length: got %d, % X, %v", advance, token, err)
+	}
+	if _, err := protocol.Split([]byte{0x68, 0xF0}); !errors.Is(err, ErrLengthMismatch) {
+		t.Fatalf("want ErrLengthMismatch, got %v", err)
+	}
+
+	// 校验码不通过的垃圾数据不计入校验失败
+	data = append([]byte{0x68, 0x02, 0x00, 0x03, 0xAA, 0xBB}, frame...)
+	if _, token, err := split(data, false); err != nil || !bytes.Equal(token, frame) {
+		t.Fatalf("bad checksum: got % X, %v", token, err)
+	}
+	if n := metrics.checksumFailures.Load(); n != 0 {
+		t.Fatalf("want no checksum failures while scanning, got %d", n)
+	}
+}
+
+// oneByteReader 每次只返回一个字节, 模拟分片到达的数据
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
@@ -0,0 +1,200 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// defaultDispatchQueueDepth 等待处理的帧数上限的默认值
+const defaultDispatchQueueDepth = 1024
+
+// ErrOverloaded 处理队列已满, 过载策略为 OverloadReject 时作为处理函数错误交给错误策略
+var ErrOverloaded = errors.New("处理队列已满")
+
+// DispatchOrder 异步处理模式下的顺序保证
+type DispatchOrder int
+
+const (
+	OrderSession     DispatchOrder = iota // 同一会话的帧按到达顺序依次处理
+	OrderSessionCode                      // 同一会话同一功能码的帧按到达顺序依次处理, 不同功能码可以并行
+)
+
+// OverloadPolicy 处理队列满时的策略
+type OverloadPolicy int
+
+const (
+	OverloadBlock  OverloadPolicy = iota // 读取协程等待队列有空位, 背压传递到对端
+	OverloadReject                       // 不处理该帧, 以 ErrOverloaded 作为处理函数错误交给错误策略(如回复NACK)
+)
+
+// DispatchConfig 异步处理模式的配置
+type DispatchConfig struct {
+	Workers    int            // 同时执行的处理函数数量, 为0时使用GOMAXPROCS
+	QueueDepth int            // 所有会话等待处理和正在处理的帧数上限, 为0时为1024
+	Order      DispatchOrder  // 顺序保证
+	Overload   OverloadPolicy // 队列满时的策略
+}
+
+// dispatchKey 顺序处理的单位, 按会话顺序时code为0
+type dispatchKey struct {
+	st   *decodeState
+	code FunctionCode
+}
+
+// dispatcher 处理函数的工作协程池. 每个有待处理帧的dispatchKey由一个协程依次执行,
+// 每执行一帧占用一个工作名额, 因此同一个key的帧有序, 总并发不超过Workers
+type dispatcher struct {
+	order    DispatchOrder
+	overload OverloadPolicy
+	workers  chan struct{}
+	slots    chan struct{}
+
+	mu     sync.Mutex
+	queues map[dispatchKey][]func()
+}
+
+func newDispatcher(config DispatchConfig) *dispatcher {
+	if config.Workers <= 0 {
+		config.Workers = runtime.GOMAXPROCS(0)
+	}
+	if config.QueueDepth <= 0 {
+		config.QueueDepth = defaultDispatchQueueDepth
+	}
+	return &dispatcher{
+		order:    config.Order,
+		overload: config.Overload,
+		workers:  make(chan struct{}, config.Workers),
+		slots:    make(chan struct{}, config.QueueDepth),
+		queues:   make(map[dispatchKey][]func()),
+	}
+}
+
+// acquire 占用一个队列名额
+func (d *dispatcher) acquire(ctx context.Context) error {
+	if d.overload == OverloadReject {
+		select {
+		case d.slots <- struct{}{}:
+			return nil
+		default:
+			return ErrOverloaded
+		}
+	}
+	select {
+	case d.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// submit 将任务追加到key的队列, key没有正在执行的协程时启动一个
+func (d *dispatcher) submit(key dispatchKey, task func()) {
+	d.mu.Lock()
+	q, running := d.queues[key]
+	d.queues[key] = append(q, task)
+	d.mu.Unlock()
+	if !running {
+		go d.run(key)
+	}
+}
+
+// run 依次执行key队列中的任务, 队列为空时退出
+func (d *dispatcher) run(key dispatchKey) {
+	for {
+		d.mu.Lock()
+		q := d.queues[key]
+		if len(q) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		task := q[0]
+		q[0] = nil
+		d.queues[key] = q[1:]
+		d.mu.Unlock()
+
+		d.workers <- struct{}{}
+		task()
+		<-d.workers
+		<-d.slots
+	}
+}
+
+// dispatch 将处理函数交给工作协程池执行. 处理函数的错误在工作协程中按错误策略处理,
+// 需要停止会话时记录错误并打断读取协程
+func (st *decodeState) dispatch(hc *HandlerContext) error {
+	if _, ok := st.handlerMap[hc.Code]; !ok && st.defaultHandler == nil {
+		// 未配置处理函数的帧不进入队列, 直接按帧级错误处理
+		return st.doHandle(st, hc)
+	}
+	d := st.dispatcher
+	if err := d.acquire(st.ctx); err != nil {
+		if st.ctx.Err() != nil {
+			// 会话即将结束, 丢弃该帧
+			return nil
+		}
+		return err
+	}
+	key := dispatchKey{st: st}
+	if d.order == OrderSessionCode {
+		key.code = hc.Code
+	}
+	st.pending.Add(1)
+	st.metrics.handlerQueued(1)
+	d.submit(key, func() {
+		defer st.pending.Done()
+		defer st.metrics.handlerQueued(-1)
+		// 异步执行时读取协程已在处理后续帧, 错误偏移无法计算, 只使用不可变的Frame
+		err := st.doHandle(nil, hc)
+		if err == nil {
+			return
+		}
+		element := st.GetElementByType(Payload)
+		hc.Logger().Warn("处理函数执行失败", "code", hc.Code, "err", err)
+		if serveErr := st.applyErrorPolicy(&FrameError{
+			Class:   classifyFrameError(element, err),
+			Element: element,
+			Raw:     hc.Frame.raw,
+			Frame:   hc.Frame,
+			Err:     err,
+		}); serveErr != nil {
+			st.failAsync(serveErr)
+		}
+	})
+	return nil
+}
+
+// failAsync 记录处理函数引起的停止错误, 并打断读取协程
+func (st *decodeState) failAsync(err error) {
+	st.asyncMu.Lock()
+	if st.asyncErr == nil {
+		st.asyncErr = err
+	}
+	st.asyncMu.Unlock()
+	if conn := st.session.Conn(); conn.SetReadDeadline(aLongTimeAgo) != nil {
+		conn.Close()
+	}
+}
+
+// asyncError 返回处理函数引起的停止错误
+func (st *decodeState) asyncError() error {
+	st.asyncMu.Lock()
+	defer st.asyncMu.Unlock()
+	return st.asyncErr
+}
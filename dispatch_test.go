@@ -0,0 +1,173 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longan55/Rules-over-TCP/fake"
+)
+
+// testFrame 按测试协议组帧: 起始符 长度 加密标识 功能码 负载 ModBusCRC
+func testFrame(code FunctionCode, payload []byte) []byte {
+	frame := append([]byte{0x68, byte(len(payload) + 2), 0x00, byte(code)}, payload...)
+	return append(frame, ModBusCRC(frame[2:])...)
+}
+
+func TestDispatch_PerSessionOrder(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[byte][]byte{}
+	protocol, err := newTestBuilder().
+		SetDispatch(DispatchConfig{Workers: 4}).
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			// 不同会话的处理函数交错执行
+			time.Sleep(time.Duration(hc.Payload[1]%3) * time.Millisecond)
+			mu.Lock()
+			seen[hc.Payload[0]] = append(seen[hc.Payload[0]], hc.Payload[1])
+			mu.Unlock()
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("session", WithBin(), WithLength(1), WithInteger(true, 1, 0))
+			fh.AddField("seq", WithBin(), WithLength(1), WithInteger(true, 1, 0))
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for session := range byte(4) {
+		conn := fake.NewFakeConn()
+		for seq := range byte(30) {
+			conn.SetData(testFrame(0x03, []byte{session, seq}))
+		}
+		wg.Go(func() {
+			protocol.Serve(context.Background(), conn)
+		})
+	}
+	// Serve返回前等待该会话的处理函数执行完
+	wg.Wait()
+	for session, seqs := range seen {
+		if len(seqs) != 30 {
+			t.Fatalf("session %d: want 30 frames, got %d", session, len(seqs))
+		}
+		for i, seq := range seqs {
+			if seq != byte(i) {
+				t.Fatalf("session %d handled out of order: %v", session, seqs)
+			}
+		}
+	}
+}
+
+func TestDispatch_PerCodeOrder(t *testing.T) {
+	fast := make(chan struct{})
+	asciiField := func(fh *FunctionHandler) {
+		fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+	}
+	protocol, err := newTestBuilder().
+		SetDispatch(DispatchConfig{Workers: 2, Order: OrderSessionCode}).
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			// 慢处理函数不阻塞同一会话中其他功能码的处理
+			select {
+			case <-fast:
+				return nil
+			case <-time.After(time.Second):
+				return errors.New("0x04 handler blocked behind slow 0x03 handler")
+			}
+		}, asciiField).
+		HandleFuncWithParse(FunctionCode(0x04), func(hc *HandlerContext) error {
+			close(fast)
+			return nil
+		}, asciiField).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData(testFrame(0x03, []byte("0123")))
+	conn.SetData(testFrame(0x04, []byte("0123")))
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+		t.Fatalf("want reason %v, got %v", StopEOF, err)
+	}
+}
+
+func TestDispatch_Overload(t *testing.T) {
+	release := make(chan struct{})
+	var frameErrors []*FrameError
+	protocol, err := newTestBuilder().
+		SetDispatch(DispatchConfig{Workers: 1, QueueDepth: 1, Overload: OverloadReject}).
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			<-release
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionEscalate, map[FrameErrorClass]ErrorAction{ClassHandler: ActionSkip})).
+		OnFrameError(func(fe *FrameError) {
+			frameErrors = append(frameErrors, fe)
+			if len(frameErrors) == 2 {
+				close(release)
+			}
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一帧占满队列, 后两帧被拒绝后跳过
+	conn := fake.NewFakeConn()
+	for range 3 {
+		conn.SetData(testFrame(0x03, []byte("0123")))
+	}
+	protocol.Serve(context.Background(), conn)
+	if len(frameErrors) != 2 {
+		t.Fatalf("want 2 overload errors, got %d", len(frameErrors))
+	}
+	for _, fe := range frameErrors {
+		if !errors.Is(fe, ErrOverloaded) || fe.Class != ClassHandler {
+			t.Fatalf("unexpected frame error: %v", fe)
+		}
+	}
+}
+
+func TestDispatch_HandlerErrorStopsSession(t *testing.T) {
+	handlerErr := errors.New("database unavailable")
+	protocol, err := newTestBuilder().
+		SetDispatch(DispatchConfig{}).
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			return handlerErr
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData(testFrame(0x03, []byte("0123")))
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopProtocol || !errors.Is(err, handlerErr) {
+		t.Fatalf("want handler error with reason %v, got %v", StopProtocol, err)
+	}
+}
@@ -53,58 +53,84 @@ import (
 //      解释为：BITMAP（可表示多种意义）
 // ASCII: 每个字节表示一个ASCII码，直接将字节序列转换为字符串。最终解释的类型：字符串、也可以解释为数字字符的字面值数值（不常见）
 
+// BIN2Uint64 将1-8字节的BIN码解释为无符号整数, 3/5/6/7字节按大端或小端补齐
 func BIN2Uint64(bin []byte, order binary.ByteOrder) (uint64, error) {
-	len := len(bin)
-	switch len {
+	switch len(bin) {
 	case 1:
 		return uint64(bin[0]), nil
 	case 2:
 		return uint64(order.Uint16(bin)), nil
-	case 3, 4:
-		bin4 := make([]byte, 8)
-		copy(bin4[4-len:], bin) //前面字节填充0
-		return uint64(order.Uint32(bin4)), nil
-	case 5, 6, 7, 8:
-		bin8 := make([]byte, 8)
-		copy(bin8[8-len:], bin)
-		return order.Uint64(bin8), nil
+	case 4:
+		return uint64(order.Uint32(bin)), nil
+	case 8:
+		return order.Uint64(bin), nil
+	case 3, 5, 6, 7:
+		var b8 [8]byte
+		if isLittleEndian(order) {
+			copy(b8[:], bin) //高位字节在后, 后面填充0
+		} else {
+			copy(b8[8-len(bin):], bin) //前面字节填充0
+		}
+		return order.Uint64(b8[:]), nil
 	default:
 		return 0, errors.New("不符合字节长度范围1-8")
 	}
 }
 
+// endianProbe 用于判断字节序的两个字节
+var endianProbe = []byte{0x01, 0x00}
+
+// isLittleEndian 判断字节序的低位字节是否在前
+func isLittleEndian(order binary.ByteOrder) bool {
+	return order.Uint16(endianProbe) == 1
+}
+
 // 无符号整形转BIN码
 var ErrLength = errors.New("需要更大的长度存储该数值")
 
-// len: 最长8字节
+// Uint2BIN 将无符号整数编码为len(1-8)字节的BIN码, 超出该长度的表示范围时返回 ErrLength
 func Uint2BIN(n uint64, len uint8, order binary.ByteOrder) ([]byte, error) {
+	if len < 1 || len > 8 {
+		return nil, errors.New("非法字节长度")
+	}
+	if len < 8 && n>>(8*len) != 0 {
+		return nil, ErrLength
+	}
+	b := make([]byte, len)
 	switch len {
 	case 1:
-		if n > math.MaxUint8 {
-			return nil, ErrLength
-		}
-		return []byte{byte(n)}, nil
+		b[0] = byte(n)
 	case 2:
-		if n > math.MaxUint16 {
-			return nil, ErrLength
-		}
-		b := make([]byte, 2)
 		order.PutUint16(b, uint16(n))
-		return b, nil
-	case 3, 4:
-		if n > math.MaxUint32 {
-			return nil, ErrLength
-		}
-		b := make([]byte, 4)
+	case 4:
 		order.PutUint32(b, uint32(n))
-		return b, nil
-	case 5, 6, 7, 8:
-		b := make([]byte, 8)
+	case 8:
 		order.PutUint64(b, n)
-		return b, nil
 	default:
+		var b8 [8]byte
+		order.PutUint64(b8[:], n)
+		if isLittleEndian(order) {
+			copy(b, b8[:len])
+		} else {
+			copy(b, b8[8-len:])
+		}
+	}
+	return b, nil
+}
+
+// Int2BIN 将有符号整数编码为len(1-8)字节的补码BIN码, 超出该长度的表示范围时返回 ErrLength
+func Int2BIN(n int64, len uint8, order binary.ByteOrder) ([]byte, error) {
+	if len < 1 || len > 8 {
 		return nil, errors.New("非法字节长度")
 	}
+	if bits := 8 * uint(len); bits < 64 && (n < -1<<(bits-1) || n > 1<<(bits-1)-1) {
+		return nil, ErrLength
+	}
+	u := uint64(n)
+	if len < 8 {
+		u &= 1<<(8*uint(len)) - 1
+	}
+	return Uint2BIN(u, len, order)
 }
 
 func Uint16ToBin(i uint16, order binary.ByteOrder) []byte {
@@ -171,6 +197,11 @@ func Bin2Float64(order binary.ByteOrder, b []byte, bit int) (float64, error) {
 	return num, nil
 }
 
+// Bin2Decimal b:Bin码[]byte, scale:小数位数, 按补码解释为定点小数, 不经过浮点数
+func Bin2Decimal(order binary.ByteOrder, b []byte, scale int) Decimal {
+	return NewDecimal(int64(Bin2Int(b, order)), scale)
+}
+
 // Float64ToBin  f:浮点数, byteLength:字节数, bit:小数点位数
 func Float64ToBin(f float64, byteLength byte, bit int) []byte {
 	i := int(f * math.Pow10(bit))
@@ -216,89 +247,29 @@ func Hex2Byte(str string) []byte {
 // 	return result, nil
 // }
 
+// Bin2Int 将1-8字节的BIN码按补码解释为有符号整数, 默认大端序
 func Bin2Int(b []byte, orders ...binary.ByteOrder) int {
 	var order binary.ByteOrder = binary.BigEndian // 默认使用大端序，与Int2Bin保持一致
 	if orders != nil {
 		order = orders[0]
 	}
-	if len(b) == 3 {
-		b = append([]byte{0}, b...) // 3字节特殊处理，扩展为4字节
+	u, err := BIN2Uint64(b, order)
+	if err != nil {
+		return 0
 	}
-	bytesBuffer := bytes.NewBuffer(b)
-	switch len(b) {
-	case 1:
-		var tmp int8 // 使用有符号整数类型以正确处理负数
-		err := binary.Read(bytesBuffer, order, &tmp)
-		if err != nil {
-			return 0
-		}
-		return int(tmp)
-	case 2:
-		var tmp int16
-		err := binary.Read(bytesBuffer, order, &tmp)
-		if err != nil {
-			return 0
-		}
-		return int(tmp)
-	case 4:
-		var tmp int32
-		err := binary.Read(bytesBuffer, order, &tmp)
-		if err != nil {
-			return 0
-		}
-		return int(tmp)
-	case 8:
-		var tmp int64
-		err := binary.Read(bytesBuffer, order, &tmp)
-		if err != nil {
-			return 0
-		}
-		return int(tmp) // 在32位系统上可能会被截断，但这是int类型的限制
-	default:
-		// 对于不支持的字节长度，尝试作为有符号整数处理
-		// 首先检查第一个字节的最高位是否为1（表示负数）
-		signed := false
-		if len(b) > 0 && b[0]&0x80 != 0 {
-			signed = true
-		}
-
-		// 如果是有符号负数，使用补码规则处理
-		if signed {
-			// 计算对应正数的补码值
-			complement := make([]byte, len(b))
-			for i := range complement {
-				complement[i] = ^b[i]
-			}
-			// 加1得到原码
-			for i := len(complement) - 1; i >= 0; i-- {
-				complement[i]++
-				if complement[i] != 0 {
-					break // 没有进位，结束
-				}
-			}
-			// 计算补码对应的整数值
-			val := 0
-			for i := 0; i < len(complement); i++ {
-				if order == binary.BigEndian {
-					val = val<<8 | int(complement[i])
-				} else {
-					val = val | int(complement[i])<<(8*i)
-				}
-			}
-			return -val
-		}
+	// 符号扩展
+	shift := 64 - 8*uint(len(b))
+	return int(int64(u<<shift) >> shift)
+}
 
-		// 无符号数处理
-		val := 0
-		for i := 0; i < len(b); i++ {
-			if order == binary.BigEndian {
-				val = val<<8 | int(b[i])
-			} else {
-				val = val | int(b[i])<<(8*i)
-			}
-		}
-		return val
+// Bin2Uint 将1-8字节的BIN码解释为无符号整数, 默认大端序; 用于长度、功能码等不会为负的值
+func Bin2Uint(b []byte, orders ...binary.ByteOrder) uint64 {
+	var order binary.ByteOrder = binary.BigEndian
+	if orders != nil {
+		order = orders[0]
 	}
+	u, _ := BIN2Uint64(b, order)
+	return u
 }
 
 func Int2Bin(n int, bytesLength byte, order binary.ByteOrder) []byte {
@@ -347,37 +318,84 @@ func Int2Bin(n int, bytesLength byte, order binary.ByteOrder) []byte {
 	return nil
 }
 
-// ParseCP56time2a 解析CP56time2a 为字符串时间  7字节
+// ParseCP56time2a 解析CP56time2a 为字符串时间  7字节, 无效时间返回空字符串
+//
+// Deprecated: 使用 WithCP56Time2a 将字段解码为 time.Time, 可以保留毫秒和无效、夏令时标志
 func ParseCP56time2a(b []byte) string {
-	second := ((int64(b[1]) << 8) + int64(b[0])) / 1000
-	min := b[2] & 0x3F
-	hour := b[3] & 0x1F
-	day := b[4] & 0x1F
-	month := b[5] & 0xF
-	year := b[6] & 0x7F
-	return fmt.Sprintf("20%d-%02d-%02d %02d:%02d:%02d",
-		year, month, day, hour, min, second)
+	t, err := NewCodecTime(TimeCP56Time2a, binary.LittleEndian, time.UTC).decodeCP56(b)
+	if err != nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateTime)
 }
 
-// EncodeCP56time2a 字符串时间编码为CP56time2a格式
+// EncodeCP56time2a 字符串时间编码为CP56time2a格式, 无法解析时置位无效标志
+//
+// Deprecated: 使用 WithCP56Time2a 从 time.Time 编码
 func EncodeCP56time2a(str string) []byte {
-	var b = []byte{0, 0, 0, 0, 0, 0, 0}
-	parse, err := time.Parse("2006-01-02 15:04:05", str)
+	t, err := time.Parse(time.DateTime, str)
 	if err != nil {
-		return b
+		t = time.Time{}
+	}
+	b, err := NewCodecTime(TimeCP56Time2a, binary.LittleEndian, time.UTC).encodeCP56(t)
+	if err != nil {
+		return []byte{0, 0, 0x80, 0, 0, 0, 0}
 	}
-	second := Int2Bin(int(parse.Second())*1000, 2, binary.LittleEndian)
-	min := byte(parse.Minute())
-	hour := byte(parse.Hour())
-	day := byte(parse.Day())
-	month := byte(parse.Month())
-	year := byte(parse.Year() - 2000)
-	b[0] = second[0]
-	b[1] = second[1]
-	b[2] = min
-	b[3] = hour
-	b[4] = day
-	b[5] = month
-	b[6] = year
 	return b
 }
+
+var (
+	// BigEndianWordSwap 16位字内大端、字间小端的字节序(CDAB), 常见于Modbus设备的32/64位数值
+	BigEndianWordSwap binary.ByteOrder = wordSwapOrder{binary.BigEndian}
+	// LittleEndianWordSwap 16位字内小端、字间大端的字节序(BADC)
+	LittleEndianWordSwap binary.ByteOrder = wordSwapOrder{binary.LittleEndian}
+)
+
+// wordSwapOrder 在base字节序的基础上反转16位字的顺序, 16位数值不受影响
+type wordSwapOrder struct {
+	base binary.ByteOrder
+}
+
+// swapWords 反转b中16位字的顺序, b的长度必须是偶数
+func swapWords(dst, b []byte) {
+	n := len(b)
+	for i := 0; i < n; i += 2 {
+		dst[n-2-i], dst[n-1-i] = b[i], b[i+1]
+	}
+}
+
+func (o wordSwapOrder) Uint16(b []byte) uint16 {
+	return o.base.Uint16(b)
+}
+
+func (o wordSwapOrder) Uint32(b []byte) uint32 {
+	var buf [4]byte
+	swapWords(buf[:], b[:4])
+	return o.base.Uint32(buf[:])
+}
+
+func (o wordSwapOrder) Uint64(b []byte) uint64 {
+	var buf [8]byte
+	swapWords(buf[:], b[:8])
+	return o.base.Uint64(buf[:])
+}
+
+func (o wordSwapOrder) PutUint16(b []byte, v uint16) {
+	o.base.PutUint16(b, v)
+}
+
+func (o wordSwapOrder) PutUint32(b []byte, v uint32) {
+	var buf [4]byte
+	o.base.PutUint32(buf[:], v)
+	swapWords(b[:4], buf[:])
+}
+
+func (o wordSwapOrder) PutUint64(b []byte, v uint64) {
+	var buf [8]byte
+	o.base.PutUint64(buf[:], v)
+	swapWords(b[:8], buf[:])
+}
+
+func (o wordSwapOrder) String() string {
+	return o.base.String() + "WordSwap"
+}
@@ -0,0 +1,171 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// 协议错误种类, 可通过 errors.Is 判断, 通过 errors.As 获取 *ProtocolError 详情
+var (
+	// ErrBadPreamble 起始符错误
+	ErrBadPreamble = errors.New("起始符错误")
+	// ErrChecksumMismatch 校验码错误
+	ErrChecksumMismatch = errors.New("校验码错误")
+	// ErrUnknownFunction 功能码未配置处理函数
+	ErrUnknownFunction = errors.New("未配置处理函数")
+	// ErrLengthMismatch 数据长度与定义不符
+	ErrLengthMismatch = errors.New("长度不匹配")
+	// ErrDecryptFailed 负载解密失败
+	ErrDecryptFailed = errors.New("解密失败")
+	// ErrSerialNumber 序列号与同一会话的上一个数据单元相同或回退
+	ErrSerialNumber = errors.New("序列号重复")
+)
+
+// ProtocolError 协议错误详情
+type ProtocolError struct {
+	Kind     error           // 错误种类, 为 ErrBadPreamble 等之一
+	Element  ProtocolElement // 出错的元素, 未知时为nil
+	Expected []byte          // 期望的字节
+	Actual   []byte          // 实际的字节
+	Offset   int             // 出错元素在帧中的字节偏移(负载解析错误时为负载内偏移), 未知时为-1
+	Err      error           // 底层错误
+}
+
+func (e *ProtocolError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Kind.Error())
+	if e.Element != nil {
+		fmt.Fprintf(&b, " element=%s", strings.TrimSpace(e.Element.GetName()))
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, " offset=%d", e.Offset)
+	}
+	if e.Expected != nil {
+		fmt.Fprintf(&b, " need=[% X]", e.Expected)
+	}
+	if e.Actual != nil {
+		fmt.Fprintf(&b, " but=[% X]", e.Actual)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+// Is 使 errors.Is(err, ErrXxx) 按错误种类匹配
+func (e *ProtocolError) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// newProtocolError 创建元素相关的协议错误, 偏移量由pdu中前序元素的原始数据长度计算,
+// 字节会被复制, 因为元素的原始数据指向会话复用的帧缓冲区
+func newProtocolError(kind error, element ProtocolElement, pdu ProtocolDataUnitAccessor, expected, actual []byte, err error) *ProtocolError {
+	offset := -1
+	if element != nil && pdu != nil {
+		offset = 0
+		for i := 0; i < element.GetIndex(); i++ {
+			if e := pdu.GetElementByIndex(i); e != nil {
+				offset += len(pdu.Source(e))
+			}
+		}
+	}
+	return &ProtocolError{
+		Kind:     kind,
+		Element:  element,
+		Expected: bytes.Clone(expected),
+		Actual:   bytes.Clone(actual),
+		Offset:   offset,
+		Err:      err,
+	}
+}
+
+// StopReason Serve 停止的原因
+type StopReason int
+
+const (
+	// 上下文被取消
+	StopCanceled StopReason = iota
+	// 对端关闭连接
+	StopEOF
+	// 读取超时
+	StopTimeout
+	// 协议错误: 帧格式、校验、处理函数等失败
+	StopProtocol
+	// 其他传输层错误
+	StopIO
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopCanceled:
+		return "canceled"
+	case StopEOF:
+		return "eof"
+	case StopTimeout:
+		return "timeout"
+	case StopProtocol:
+		return "protocol"
+	case StopIO:
+		return "io"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+// ServeError Serve 返回的错误, Reason 说明停止原因, Err 为原始错误
+type ServeError struct {
+	Reason StopReason
+	Err    error
+}
+
+func (e *ServeError) Error() string {
+	return fmt.Sprintf("serve stopped (%s): %v", e.Reason, e.Err)
+}
+
+func (e *ServeError) Unwrap() error {
+	return e.Err
+}
+
+// readStopError 将读取阶段的错误归类为 ServeError, 上下文已取消时优先视为取消
+func readStopError(ctx context.Context, err error) *ServeError {
+	if ctx.Err() != nil {
+		return &ServeError{Reason: StopCanceled, Err: context.Cause(ctx)}
+	}
+	var netErr net.Error
+	var protocolErr *ProtocolError
+	switch {
+	case errors.As(err, &protocolErr):
+		return &ServeError{Reason: StopProtocol, Err: err}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed),
+		errors.Is(err, os.ErrClosed), errors.Is(err, io.ErrClosedPipe):
+		return &ServeError{Reason: StopEOF, Err: err}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return &ServeError{Reason: StopTimeout, Err: err}
+	default:
+		return &ServeError{Reason: StopIO, Err: err}
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
 	rot "github.com/longan55/Rules-over-TCP"
@@ -29,6 +31,8 @@ func main() {
 	builder := rot.NewProtocolBuilder()
 	//default is BigEndian, if is BigEndian,this can be not called
 	builder.SetDefaultOrder(binary.BigEndian)
+	//输出调试日志, 默认不输出任何日志
+	builder.SetLogger(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	//构建处理器
 	builder.AddElement(rot.NewStarter([]byte{0x68})).
 		AddElement(rot.NewDataLen(1)).
@@ -72,8 +76,8 @@ func RegisterHandlerConfig(builder *rot.ProtocolBuilder) {
 			AddField("c", rot.WithBin(), rot.WithLength(2), rot.WithInteger(true, 2, 0)).
 			AddField("d", rot.WithBin(), rot.WithLength(2), rot.WithFloat(true, 0.01, 0)).
 			AddField("e", rot.WithBin(), rot.WithLength(1), rot.WithInteger(true, 1, 0), rot.WithEnum("Other", map[int]any{0: "A", 1: "B", 2: "C"})).
-			SetHandler(func(parsedData map[string]rot.ParsedData) error {
-				fmt.Println("parsedData:", parsedData)
+			SetHandler(func(hc *rot.HandlerContext) error {
+				fmt.Println("parsedData:", hc.Parsed)
 				return nil
 			})
 	})
@@ -86,15 +90,15 @@ func ParseHandle02(fh *rot.FunctionHandler) {
 	fh.AddField("code", rot.WithBcd(), rot.WithLength(4), rot.WithString()).
 		AddField("price", rot.WithBcd(), rot.WithLength(4), rot.WithFloat(true, 0.0001, 0)).
 		AddField("intPrice", rot.WithBcd(), rot.WithLength(4), rot.WithInteger(true, 1, 0)).
-		SetHandler(func(parsedData map[string]rot.ParsedData) error {
-			fmt.Println("parsedData:", parsedData)
+		SetHandler(func(hc *rot.HandlerContext) error {
+			fmt.Println("parsedData:", hc.Parsed)
 			return nil
 		})
 }
 func ParseHandle03(fh *rot.FunctionHandler) {
 	fh.AddField("ascii", rot.WithAscii(), rot.WithLength(4), rot.WithString()).
-		SetHandler(func(parsedData map[string]rot.ParsedData) error {
-			fmt.Println("parsedData:", parsedData)
+		SetHandler(func(hc *rot.HandlerContext) error {
+			fmt.Println("parsedData:", hc.Parsed)
 			return nil
 		})
 }
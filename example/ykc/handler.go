@@ -11,15 +11,15 @@ func RegisterHandlers(builder *rot.ProtocolBuilder) {
 	builder.HandleFunc(rot.FunctionCode(0x02), ParseHandle02)
 	builder.HandleFunc(rot.FunctionCode(0x03), func(fh *rot.FunctionHandler) {
 		fh.AddField("ascii", rot.WithAscii(), rot.WithLength(4), rot.WithString())
-		fh.SetHandler(func(parsedData map[string]rot.ParsedData) error {
-			fmt.Println("parsedData03:", parsedData)
+		fh.SetHandler(func(hc *rot.HandlerContext) error {
+			fmt.Println("parsedData03:", hc.Parsed)
 			return nil
 		})
 	})
 }
 
-func Handle01(parsedData map[string]rot.ParsedData) error {
-	fmt.Println("parsedData01:", parsedData)
+func Handle01(hc *rot.HandlerContext) error {
+	fmt.Println("parsedData01:", hc.Parsed)
 	return nil
 }
 
@@ -36,8 +36,8 @@ func ParseHandle02(fh *rot.FunctionHandler) {
 	fh.AddField("code", rot.WithBcd(), rot.WithLength(4), rot.WithString())
 	fh.AddField("price", rot.WithBcd(), rot.WithLength(4), rot.WithFloat(true, 0.0001, 0))
 	fh.AddField("intPrice", rot.WithBcd(), rot.WithLength(4), rot.WithInteger(true, 1, 0))
-	fh.SetHandler(func(parsedData map[string]rot.ParsedData) error {
-		fmt.Println("parsedData02:", parsedData)
+	fh.SetHandler(func(hc *rot.HandlerContext) error {
+		fmt.Println("parsedData02:", hc.Parsed)
 		return nil
 	})
 }
@@ -18,6 +18,8 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
 	rot "github.com/longan55/Rules-over-TCP"
@@ -31,6 +33,8 @@ func main() {
 	builder = rot.NewProtocolBuilder()
 	//default is BigEndian, if is BigEndian,this can be not called
 	builder.SetDefaultOrder(binary.BigEndian)
+	//输出调试日志, 默认不输出任何日志
+	builder.SetLogger(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	//构建处理器
 	builder.AddElement(rot.NewStarter([]byte{0x68})).
 		AddElement(rot.NewDataLen(1)).
@@ -18,8 +18,12 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 )
 
+// ErrUnknownEnum 编码时标签不在枚举中, 或严格枚举解码时值不在枚举中
+var ErrUnknownEnum = errors.New("未定义的枚举值")
+
 // CodecMode 编解码模式
 type CodecMode int
 
@@ -40,12 +44,19 @@ type FieldCodecConfig struct {
 
 // ExplainConfig 数据解释配置
 type ExplainConfig struct {
-	moflag   bool
-	multiple float64     // 倍数
-	offset   float64     // 偏移量
-	other    string      // 其他解释
-	enum     map[int]any // 枚举映射
-	bitmap   map[int]any // 位图映射
+	moflag     bool
+	multiple   float64     // 倍数
+	offset     float64     // 偏移量
+	other      string      // 其他解释
+	enum       map[int]any // 枚举映射
+	strictEnum bool        // 严格枚举, 解码时值不在枚举中返回错误而不是使用 other
+	bitmap     map[int]any // 位图映射
+	bitFields  []bitField  // 位图中的多比特子字段
+}
+
+// isBitmap 是否按位图解释
+func (ec *ExplainConfig) isBitmap() bool {
+	return ec != nil && (ec.bitmap != nil || len(ec.bitFields) > 0)
 }
 
 // NewFieldCodecConfig 创建新的字段编解码配置
@@ -77,7 +88,15 @@ func (config *FieldCodecConfig) Decode(data []byte) (*ParsedData, error) {
 	if err != nil {
 		return nil, err
 	}
-	explainedValue := config.dataTyper.Explain(rawValue)
+	// 未设置解释器时直接使用编解码器的结果(如浮点数)
+	explainedValue := rawValue
+	if dt, ok := config.dataTyper.(checkedDataTyper); ok {
+		if explainedValue, err = dt.explain(rawValue); err != nil {
+			return nil, fmt.Errorf("field %s: %w", config.name, err)
+		}
+	} else if config.dataTyper != nil {
+		explainedValue = config.dataTyper.Explain(rawValue)
+	}
 
 	parsed := &ParsedData{
 		Bytes:     data,
@@ -85,14 +104,28 @@ func (config *FieldCodecConfig) Decode(data []byte) (*ParsedData, error) {
 		Explained: explainedValue,
 	}
 
+	if config.explainConfig.isBitmap() {
+		v, err := bitmapValue(explainedValue, len(data)*8)
+		if err != nil {
+			return nil, err
+		}
+		parsed.Explained = config.explainConfig.explainBitmap(v, len(data)*8)
+		return parsed, nil
+	}
+
 	if config.explainConfig != nil && config.explainConfig.enum != nil {
 		i, ok := explainedValue.(int)
+		if u, isUint := explainedValue.(uint64); isUint && u <= math.MaxInt {
+			i, ok = int(u), true
+		}
 		if !ok {
 			return nil, fmt.Errorf("explained value is not int: %v", explainedValue)
 		}
 		if enumValue, ok := config.explainConfig.enum[i]; ok {
 			parsed.Explained = enumValue
 			return parsed, nil
+		} else if config.explainConfig.strictEnum {
+			return nil, fmt.Errorf("field %s: %w: %d", config.name, ErrUnknownEnum, i)
 		} else {
 			parsed.Explained = config.explainConfig.other
 			return parsed, nil
@@ -102,13 +135,75 @@ func (config *FieldCodecConfig) Decode(data []byte) (*ParsedData, error) {
 	return parsed, nil
 }
 
+// hasEnumCode 返回data是否为枚举映射中的整数值, 与解码一致uint64按int比较
+func (ec *ExplainConfig) hasEnumCode(data any) bool {
+	var code int
+	switch v := data.(type) {
+	case int:
+		code = v
+	case uint64:
+		if v > math.MaxInt {
+			return false
+		}
+		code = int(v)
+	default:
+		return false
+	}
+	_, ok := ec.enum[code]
+	return ok
+}
+
+// enumCode 查找枚举标签对应的值, 多个值对应同一标签时取最小的值
+func (ec *ExplainConfig) enumCode(label any) (int, bool) {
+	code, found := 0, false
+	for k, v := range ec.enum {
+		if v == label && (!found || k < code) {
+			code, found = k, true
+		}
+	}
+	return code, found
+}
+
 // Encode 编码方法
 func (config *FieldCodecConfig) Encode(data any) ([]byte, error) {
 	if config.mode != ModeEncode {
 		return nil, errors.New("config is not in encode mode")
 	}
-	if config.dataTyper != nil {
-		data = config.dataTyper.UnExplain(data)
+	if config.explainConfig.isBitmap() {
+		v, err := config.explainConfig.unexplainBitmap(data, config.length*8)
+		if err != nil {
+			return nil, err
+		}
+		// 位图按无符号数编码, 最高位置位时不会被当作超出有符号数的范围
+		if codec, ok := config.codec.(*CodecBIN); ok {
+			unsigned := *codec
+			unsigned.unsigned = true
+			return unsigned.Encode(v, config.length)
+		}
+		data = v
+	} else {
+		if config.explainConfig != nil && config.explainConfig.enum != nil {
+			// 先按标签查找; 本身是枚举值的整数原样编码
+			if code, ok := config.explainConfig.enumCode(data); ok {
+				data = code
+			} else if !config.explainConfig.hasEnumCode(data) {
+				return nil, fmt.Errorf("field %s: %w: %v", config.name, ErrUnknownEnum, data)
+			}
+		}
+		if dt, ok := config.dataTyper.(checkedDataTyper); ok {
+			var err error
+			if data, err = dt.unexplain(data); err != nil {
+				return nil, fmt.Errorf("field %s: %w", config.name, err)
+			}
+			// 带小数位数的BCD码自己补足小数位, 定点小数以十进制字符串交给它, 避免小数位数被应用两次
+			if d, ok := config.dataTyper.(*dtDecimal); ok {
+				if bcd, ok := config.codec.(*CodecBCD); ok && bcd.scale > 0 {
+					data = Decimal{mantissa: data.(int64), scale: d.scale}.String()
+				}
+			}
+		} else if config.dataTyper != nil {
+			data = config.dataTyper.UnExplain(data)
+		}
 	}
 	// 使用编解码器编码
 	return config.codec.Encode(data, config.length)
@@ -0,0 +1,88 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import "time"
+
+// ElementValue 元素在一个数据单元中的原始字节和解析后的值
+type ElementValue struct {
+	Name  string
+	Type  ProtocolElementType
+	Raw   []byte
+	Value any
+}
+
+// Frame 解码后的数据单元, 每个数据单元创建一次, 除在处理函数链中填充解析后的字段外不再修改.
+// 所有字节都从会话的帧缓冲区复制而来, 可以在下一个数据单元到达后继续持有; 调用方不应修改返回的切片
+type Frame struct {
+	raw        []byte
+	headers    []ElementValue
+	code       FunctionCode
+	payload    []byte
+	fields     map[string]ParsedData
+	checksum   []byte
+	receivedAt time.Time
+}
+
+// Raw 返回完整的原始帧
+func (f *Frame) Raw() []byte {
+	return f.raw
+}
+
+// Headers 返回负载之前各元素的原始字节和解析后的值
+func (f *Frame) Headers() []ElementValue {
+	return f.headers
+}
+
+// Header 返回指定类型的头部元素
+func (f *Frame) Header(typ ProtocolElementType) (ElementValue, bool) {
+	for _, header := range f.headers {
+		if header.Type == typ {
+			return header, true
+		}
+	}
+	return ElementValue{}, false
+}
+
+// Code 返回功能码
+func (f *Frame) Code() FunctionCode {
+	return f.code
+}
+
+// Payload 返回解密后的负载
+func (f *Frame) Payload() []byte {
+	return f.payload
+}
+
+// Fields 返回解析后的字段, 功能码未配置处理函数、解析失败或在处理函数链中解析前(如中间件中)为nil
+func (f *Frame) Fields() map[string]ParsedData {
+	return f.fields
+}
+
+// Field 返回指定名称的字段
+func (f *Frame) Field(name string) (ParsedData, bool) {
+	field, ok := f.fields[name]
+	return field, ok
+}
+
+// Checksum 返回校验码
+func (f *Frame) Checksum() []byte {
+	return f.checksum
+}
+
+// ReceivedAt 返回数据单元读取完成的时间
+func (f *Frame) ReceivedAt() time.Time {
+	return f.receivedAt
+}
@@ -15,7 +15,9 @@
 package rot
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 )
 
@@ -29,7 +31,59 @@ var HandlerTest = &FunctionHandler{
 type FunctionCode byte
 
 // type Handler func(fh *FucntionHandler, data []byte) error
-type Handler func(parsed map[string]ParsedData) error
+type Handler func(hc *HandlerContext) error
+
+// HandlerContext 处理函数上下文, 每个数据单元创建一次, 在中间件和处理函数之间传递
+type HandlerContext struct {
+	Code    FunctionCode          // 功能码
+	Payload []byte                // 解密后的负载
+	Parsed  map[string]ParsedData // 解析后的字段, 在调用业务处理函数前填充
+	Frame   *Frame                // 当前数据单元, 可在处理函数返回后继续持有
+	Conn    net.Conn              // 当前连接
+	Session *Session              // 当前会话
+
+	ctx context.Context
+}
+
+// Logger 返回当前会话的日志记录器
+func (hc *HandlerContext) Logger() *slog.Logger {
+	if hc.Session == nil {
+		return discardLogger
+	}
+	return hc.Session.Logger()
+}
+
+// Context 返回Serve的上下文
+func (hc *HandlerContext) Context() context.Context {
+	if hc.ctx == nil {
+		return context.Background()
+	}
+	return hc.ctx
+}
+
+// DefaultHandler 兜底处理函数, 处理未配置处理函数的功能码, payload为解密后的负载
+type DefaultHandler func(code FunctionCode, payload []byte) error
+
+// IgnoreUnknownFunction 静默忽略未配置处理函数的功能码
+func IgnoreUnknownFunction(code FunctionCode, payload []byte) error {
+	return nil
+}
+
+// LogUnknownFunction 使用slog.Default()记录未配置处理函数的功能码后忽略
+func LogUnknownFunction(code FunctionCode, payload []byte) error {
+	return LogUnknownFunctionTo(nil)(code, payload)
+}
+
+// LogUnknownFunctionTo 返回记录日志后忽略未配置功能码的兜底处理函数, logger为nil时使用slog.Default()
+func LogUnknownFunctionTo(logger *slog.Logger) DefaultHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(code FunctionCode, payload []byte) error {
+		logger.Info("未配置处理函数的功能码", "code", hexBytes{byte(code)}, "payload", hexBytes(payload))
+		return nil
+	}
+}
 
 type HandlerConfig struct {
 	handlerMap map[FunctionCode]*FunctionHandler
@@ -75,7 +129,12 @@ func (fh *FunctionHandler) SetHandler(h Handler) *FunctionHandler {
 
 func (fh *FunctionHandler) Parse(data []byte) (map[string]ParsedData, error) {
 	if len(data) != fh.length {
-		return nil, fmt.Errorf("data length %d is not equal to function handler length %d", len(data), fh.length)
+		return nil, &ProtocolError{
+			Kind:   ErrLengthMismatch,
+			Actual: data,
+			Offset: -1,
+			Err:    fmt.Errorf("data length %d is not equal to function handler length %d", len(data), fh.length),
+		}
 	}
 	result := make(map[string]ParsedData, len(fh.fccs))
 	offset := 0
@@ -84,7 +143,12 @@ func (fh *FunctionHandler) Parse(data []byte) (map[string]ParsedData, error) {
 
 		// 检查偏移量是否越界
 		if offset+length > len(data) {
-			return nil, fmt.Errorf("field %s exceeds data bounds", impl.name)
+			return nil, &ProtocolError{
+				Kind:   ErrLengthMismatch,
+				Actual: data,
+				Offset: offset,
+				Err:    fmt.Errorf("field %s exceeds data bounds", impl.name),
+			}
 		}
 
 		input := data[offset : offset+length]
@@ -98,15 +162,22 @@ func (fh *FunctionHandler) Parse(data []byte) (map[string]ParsedData, error) {
 	return result, nil
 }
 
-func (fh *FunctionHandler) Handle(data []byte) error {
+// Handle 解析负载(上下文中尚未解析时)并调用业务处理函数
+func (fh *FunctionHandler) Handle(hc *HandlerContext) error {
 	if fh.handler == nil {
 		return fmt.Errorf("handler is nil")
 	}
-	parsed, err := fh.Parse(data)
-	if err != nil {
-		return err
+	if hc.Parsed == nil {
+		parsed, err := fh.Parse(hc.Payload)
+		if err != nil {
+			return err
+		}
+		hc.Parsed = parsed
+		if hc.Frame != nil && hc.Frame.fields == nil {
+			hc.Frame.fields = parsed
+		}
 	}
-	return fh.handler(parsed)
+	return fh.handler(hc)
 }
 
 // Encode 将字段数据编码为二进制
@@ -180,7 +251,9 @@ func (e *MessageEncoder) Encode(data map[string]any) ([]byte, error) {
 	return payload, nil
 }
 
-// EncodeAndSend 编码数据并发送
+// EncodeAndSend 编码数据并直接写入连接
+//
+// Deprecated: 直接写入连接会与会话的发送协程交错写出, 请使用 EncodeAndSendSession
 func (e *MessageEncoder) EncodeAndSend(conn net.Conn, data map[string]any) error {
 	// 编码数据
 	binaryData, err := e.Encode(data)
@@ -193,6 +266,18 @@ func (e *MessageEncoder) EncodeAndSend(conn net.Conn, data map[string]any) error
 	return err
 }
 
+// EncodeAndSendSession 编码数据并放入会话的发送队列, 由会话的发送协程整帧写出
+func (e *MessageEncoder) EncodeAndSendSession(session *Session, data map[string]any) error {
+	// 编码数据
+	binaryData, err := e.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	// 发送数据
+	return session.Send(e.funcCode, binaryData)
+}
+
 // 创建消息编码器的便捷方法
 func CreateMessageEncoder(funcCode FunctionCode, handler *FunctionHandler) *MessageEncoder {
 	return NewMessageEncoder(funcCode).SetHandler(handler)
@@ -0,0 +1,267 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets 处理函数耗时直方图的默认桶(秒)
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// Metrics 协议层指标收集器, 通过 Handler 以Prometheus文本格式暴露.
+// 所有记录方法对nil接收者安全, 未配置指标时无需判断
+type Metrics struct {
+	checksumFailures atomic.Uint64
+	decryptFailures  atomic.Uint64
+	resyncs          atomic.Uint64
+	framesDropped    atomic.Uint64
+	activeSessions   atomic.Int64
+	handlerQueue     atomic.Int64
+	bytesIn          atomic.Uint64
+	bytesOut         atomic.Uint64
+
+	mu             sync.Mutex
+	buckets        []float64
+	framesReceived map[FunctionCode]uint64
+	framesSent     map[FunctionCode]uint64
+	handlerLatency map[FunctionCode]*histogram
+}
+
+// histogram 累计直方图
+type histogram struct {
+	counts []uint64 // 与buckets一一对应, 最后一个为+Inf
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics 创建指标收集器, buckets为处理函数耗时直方图的桶(秒), 为空时使用 DefaultLatencyBuckets
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Metrics{
+		buckets:        buckets,
+		framesReceived: make(map[FunctionCode]uint64),
+		framesSent:     make(map[FunctionCode]uint64),
+		handlerLatency: make(map[FunctionCode]*histogram),
+	}
+}
+
+func (m *Metrics) frameReceived(code FunctionCode, n int) {
+	if m == nil {
+		return
+	}
+	m.bytesIn.Add(uint64(n))
+	m.mu.Lock()
+	m.framesReceived[code]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) frameSent(code FunctionCode, n int) {
+	if m == nil {
+		return
+	}
+	m.bytesOut.Add(uint64(n))
+	m.mu.Lock()
+	m.framesSent[code]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) bytesSent(n int) {
+	if m == nil {
+		return
+	}
+	m.bytesOut.Add(uint64(n))
+}
+
+func (m *Metrics) checksumFailed() {
+	if m == nil {
+		return
+	}
+	m.checksumFailures.Add(1)
+}
+
+func (m *Metrics) decryptFailed() {
+	if m == nil {
+		return
+	}
+	m.decryptFailures.Add(1)
+}
+
+// resynced 记录一次帧级错误后继续读取下一帧
+func (m *Metrics) resynced() {
+	if m == nil {
+		return
+	}
+	m.resyncs.Add(1)
+}
+
+// frameDropped 记录一个因发送队列已满被丢弃的帧
+func (m *Metrics) frameDropped() {
+	if m == nil {
+		return
+	}
+	m.framesDropped.Add(1)
+}
+
+func (m *Metrics) sessionOpened() {
+	if m == nil {
+		return
+	}
+	m.activeSessions.Add(1)
+}
+
+func (m *Metrics) sessionClosed() {
+	if m == nil {
+		return
+	}
+	m.activeSessions.Add(-1)
+}
+
+// handlerQueued 异步处理模式下等待处理和正在处理的帧数变化
+func (m *Metrics) handlerQueued(delta int64) {
+	if m == nil {
+		return
+	}
+	m.handlerQueue.Add(delta)
+}
+
+func (m *Metrics) observeHandler(code FunctionCode, d time.Duration) {
+	if m == nil {
+		return
+	}
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.handlerLatency[code]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.handlerLatency[code] = h
+	}
+	i, _ := slices.BinarySearch(m.buckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// Handler 返回以Prometheus文本格式输出指标的http.Handler, m为nil时输出空内容
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w)
+	})
+}
+
+// WriteTo 以Prometheus文本格式写出所有指标, m为nil时不写出任何内容
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	writeHeader := func(name, typ, help string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	m.mu.Lock()
+	writeHeader("rot_frames_received_total", "counter", "Frames received per function code.")
+	for _, code := range sortedCodes(m.framesReceived) {
+		fmt.Fprintf(cw, "rot_frames_received_total{code=%q} %d\n", codeLabel(code), m.framesReceived[code])
+	}
+	writeHeader("rot_frames_sent_total", "counter", "Frames sent per function code.")
+	for _, code := range sortedCodes(m.framesSent) {
+		fmt.Fprintf(cw, "rot_frames_sent_total{code=%q} %d\n", codeLabel(code), m.framesSent[code])
+	}
+	writeHeader("rot_handler_duration_seconds", "histogram", "Handler latency per function code.")
+	for _, code := range sortedCodes(m.handlerLatency) {
+		h := m.handlerLatency[code]
+		label := codeLabel(code)
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(cw, "rot_handler_duration_seconds_bucket{code=%q,le=%q} %d\n", label, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(cw, "rot_handler_duration_seconds_bucket{code=%q,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(cw, "rot_handler_duration_seconds_sum{code=%q} %s\n", label, formatFloat(h.sum))
+		fmt.Fprintf(cw, "rot_handler_duration_seconds_count{code=%q} %d\n", label, h.count)
+	}
+	m.mu.Unlock()
+
+	writeHeader("rot_checksum_failures_total", "counter", "Frames rejected by checksum verification.")
+	fmt.Fprintf(cw, "rot_checksum_failures_total %d\n", m.checksumFailures.Load())
+	writeHeader("rot_decrypt_failures_total", "counter", "Payloads that failed to decrypt.")
+	fmt.Fprintf(cw, "rot_decrypt_failures_total %d\n", m.decryptFailures.Load())
+	writeHeader("rot_resyncs_total", "counter", "Frame errors after which the session kept reading.")
+	fmt.Fprintf(cw, "rot_resyncs_total %d\n", m.resyncs.Load())
+	writeHeader("rot_dropped_frames_total", "counter", "Outbound frames dropped because the write queue was full.")
+	fmt.Fprintf(cw, "rot_dropped_frames_total %d\n", m.framesDropped.Load())
+	writeHeader("rot_active_sessions", "gauge", "Sessions currently being served.")
+	fmt.Fprintf(cw, "rot_active_sessions %d\n", m.activeSessions.Load())
+	writeHeader("rot_handler_queue_depth", "gauge", "Frames queued for or running in the handler worker pool.")
+	fmt.Fprintf(cw, "rot_handler_queue_depth %d\n", m.handlerQueue.Load())
+	writeHeader("rot_received_bytes_total", "counter", "Bytes of complete frames received.")
+	fmt.Fprintf(cw, "rot_received_bytes_total %d\n", m.bytesIn.Load())
+	writeHeader("rot_sent_bytes_total", "counter", "Bytes sent.")
+	fmt.Fprintf(cw, "rot_sent_bytes_total %d\n", m.bytesOut.Load())
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func sortedCodes[V any](m map[FunctionCode]V) []FunctionCode {
+	codes := make([]FunctionCode, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+func codeLabel(code FunctionCode) string {
+	return fmt.Sprintf("0x%02X", byte(code))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countWriter 记录写出的字节数和第一个错误
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,81 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/longan55/Rules-over-TCP/fake"
+)
+
+func TestMetrics_Handler(t *testing.T) {
+	metrics := NewMetrics(0.001, 1)
+	protocol, err := newTestBuilder().
+		SetMetrics(metrics).
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			return nil
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionSkip, nil)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x00, 0x00})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61})
+	protocol.Serve(context.Background(), conn)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	out := string(body)
+	for _, want := range []string{
+		"# TYPE rot_frames_received_total counter",
+		`rot_frames_received_total{code="0x03"} 2`,
+		`rot_frames_received_total{code="0x04"} 1`,
+		"# TYPE rot_handler_duration_seconds histogram",
+		`rot_handler_duration_seconds_bucket{code="0x03",le="+Inf"} 1`,
+		`rot_handler_duration_seconds_count{code="0x04"} 1`,
+		"rot_checksum_failures_total 1",
+		"rot_resyncs_total 1",
+		"rot_active_sessions 0",
+		"rot_received_bytes_total 30",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var metrics *Metrics
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Body.Len() != 0 {
+		t.Fatalf("want empty body, got %q", rec.Body)
+	}
+	if n, err := metrics.WriteTo(io.Discard); n != 0 || err != nil {
+		t.Fatalf("WriteTo: got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Middleware 处理函数中间件, 类似net/http中间件, 用于日志、恢复、计时、鉴权、限流等横切逻辑
+type Middleware func(next Handler) Handler
+
+// chain 按注册顺序组合中间件, 先注册的在最外层
+func chain(h Handler, middlewares ...[]Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		for j := len(middlewares[i]) - 1; j >= 0; j-- {
+			h = middlewares[i][j](h)
+		}
+	}
+	return h
+}
+
+// Recover 恢复处理函数中的panic, 并转换为错误返回
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(hc *HandlerContext) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("功能码[%#0X]处理函数panic: %v\n%s", byte(hc.Code), r, debug.Stack())
+				}
+			}()
+			return next(hc)
+		}
+	}
+}
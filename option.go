@@ -16,6 +16,8 @@ package rot
 
 import (
 	"encoding/binary"
+	"fmt"
+	"time"
 )
 
 // CodecOption 配置选项接口
@@ -57,6 +59,16 @@ func WithBinWithOrder(order binary.ByteOrder) CodecOption {
 	}}
 }
 
+// WithUint 设置BIN编解码器为无符号, 解码为uint64; 已设置BIN编解码器时保留其字节序, 否则使用默认字节序
+func WithUint() CodecOption {
+	return &signOption{unsigned: true}
+}
+
+// WithInt 设置BIN编解码器为有符号(补码), 解码为int; 这是BIN编解码器的默认行为
+func WithInt() CodecOption {
+	return &signOption{unsigned: false}
+}
+
 // WithBcd 设置BCD编解码器选项
 func WithBcd() CodecOption {
 	return &codecOption{codec: &CodecBCD{
@@ -71,11 +83,93 @@ func WithBcdWithOrder(order binary.ByteOrder) CodecOption {
 	}}
 }
 
+// WithBcdScale 设置BCD编解码器的小数位数, 最后 scale 位数字是小数位; 已设置BCD编解码器时保留其字节序
+func WithBcdScale(scale int) CodecOption {
+	return &bcdScaleOption{scale: scale}
+}
+
 // WithAscii 设置ASCII编解码器选项
 func WithAscii() CodecOption {
 	return &codecOption{codec: &CodecASCII{}}
 }
 
+// WithCharset 设置字符串编解码器选项, 默认用0x00在右侧填充
+func WithCharset(charset Charset) CodecOption {
+	return &codecOption{codec: NewCodecString(charset, 0x00, PadRight)}
+}
+
+// WithPadding 设置字符串的填充字符(如0x00、空格)和填充位置, 解码时去掉, 编码时补齐;
+// 未设置字符串编解码器时使用ASCII
+func WithPadding(pad byte, side PadSide) CodecOption {
+	return &paddingOption{pad: pad, side: side}
+}
+
+// WithHex 设置HEX编解码器选项, 解码为大写十六进制字符串
+func WithHex() CodecOption {
+	return &codecOption{codec: NewCodecHEX(DefaultOrder(), false)}
+}
+
+// WithHexWithOrder 设置HEX编解码器选项, 小端序时按相反的字节顺序解码
+func WithHexWithOrder(order binary.ByteOrder) CodecOption {
+	return &codecOption{codec: NewCodecHEX(order, false)}
+}
+
+// WithHexLower 设置HEX编解码器解码为小写十六进制字符串; 已设置HEX编解码器时保留其字节序
+func WithHexLower() CodecOption {
+	return &hexCaseOption{lower: true}
+}
+
+// WithRaw 设置原始字节编解码器选项, 解码为 []byte
+func WithRaw() CodecOption {
+	return &codecOption{codec: NewCodecRaw()}
+}
+
+// WithFloat32 设置IEEE 754单精度浮点数编解码器选项, 字段长度为4
+func WithFloat32() CodecOption {
+	return &floatCodecOption{bits: 32, order: DefaultOrder()}
+}
+
+// WithFloat32WithOrder 设置IEEE 754单精度浮点数编解码器选项, 可使用 BigEndianWordSwap 等字交换字节序
+func WithFloat32WithOrder(order binary.ByteOrder) CodecOption {
+	return &floatCodecOption{bits: 32, order: order}
+}
+
+// WithFloat64 设置IEEE 754双精度浮点数编解码器选项, 字段长度为8
+func WithFloat64() CodecOption {
+	return &floatCodecOption{bits: 64, order: DefaultOrder()}
+}
+
+// WithFloat64WithOrder 设置IEEE 754双精度浮点数编解码器选项, 可使用 BigEndianWordSwap 等字交换字节序
+func WithFloat64WithOrder(order binary.ByteOrder) CodecOption {
+	return &floatCodecOption{bits: 64, order: order}
+}
+
+// WithCP56Time2a 设置CP56Time2a时间编解码器选项, 字段长度为7, 按本地时区解释
+func WithCP56Time2a() CodecOption {
+	return &timeCodecOption{format: TimeCP56Time2a, order: binary.LittleEndian}
+}
+
+// WithBCDTime 设置BCD时间编解码器选项, 默认字段长度为6(YYMMDDhhmmss), 长度为7时为YYYYMMDDhhmmss
+func WithBCDTime() CodecOption {
+	return &timeCodecOption{format: TimeBCD, order: binary.BigEndian}
+}
+
+// WithUnixTime 设置Unix时间戳编解码器选项, format 为 TimeUnix 或 TimeUnixMilli;
+// 秒的默认字段长度为4, 毫秒为8, 可以用 WithLength 修改
+func WithUnixTime(format TimeFormat, order binary.ByteOrder) CodecOption {
+	return &timeCodecOption{format: format, order: order}
+}
+
+// WithTimeZone 设置时间编解码器使用的时区, 须在时间编解码器选项之后使用
+func WithTimeZone(location *time.Location) CodecOption {
+	return &timeZoneOption{location: location}
+}
+
+// WithBaseYear 设置两位年份的起始年份(默认2000), 须在时间编解码器选项之后使用
+func WithBaseYear(year int) CodecOption {
+	return &baseYearOption{year: year}
+}
+
 // WithLength 设置字段长度选项
 func WithLength(length int) CodecOption {
 	return &lengthOption{length}
@@ -86,7 +180,8 @@ func WithDataTyper(dataTyper DataTyper) CodecOption {
 	return &dataTyperOption{dataTyper}
 }
 
-// WithInteger 设置整数解释器选项
+// WithInteger 设置整数解释器选项, moflag为true时结果为 值×multiple+offset, 否则为 (值+offset)×multiple;
+// 无符号字段解码为uint64, 结果为负或溢出时返回错误
 func WithInteger(moflag bool, multiple int, offset int) CodecOption {
 	return &dtInteger{moflag: moflag, multiple: multiple, offset: offset}
 }
@@ -96,6 +191,15 @@ func WithFloat(moflag bool, multiple float64, offset float64) CodecOption {
 	return &dtFloat{moflag: moflag, multiple: multiple, offset: offset}
 }
 
+// WithDecimal 设置定点小数解释器选项, 用于BIN和BCD字段: 字段的整数值是以 10^-scale 为单位的尾数,
+// 解码为 scale 位小数的 Decimal, 编码时接受 Decimal 或十进制字符串; 解码和编码时小数位数超过 scale 都返回错误
+func WithDecimal(scale int) CodecOption {
+	if scale < 0 || scale > maxDecimalScale {
+		panic(fmt.Sprintf("decimal scale %d out of range 0-%d", scale, maxDecimalScale))
+	}
+	return &dtDecimal{scale: scale}
+}
+
 // WithMultiple 设置倍数选项
 func WithMultiple(multiple float64) CodecOption {
 	return &multipleOption{multiple}
@@ -106,14 +210,132 @@ func WithOffset(offset float64) CodecOption {
 	return &offsetOption{offset}
 }
 
-// WithEnum 设置枚举映射选项
+// WithEnum 设置枚举映射选项, 解码时值不在枚举中解释为 other; 编码时将标签转换回对应的值, 枚举中的整数值原样编码, 未知标签返回 ErrUnknownEnum
 func WithEnum(other string, enum map[int]any) CodecOption {
-	return &enumOption{other, enum}
+	return &enumOption{other: other, enum: enum}
 }
+
+// WithStrictEnum 设置严格的枚举映射选项, 解码时值不在枚举中返回 ErrUnknownEnum
+func WithStrictEnum(enum map[int]any) CodecOption {
+	return &enumOption{enum: enum, strict: true}
+}
+
+// WithBitmap 设置位图映射选项, 键为位序号(最低位为0), 值为该位置位时的标签;
+// 解码结果为 Bitmap, 编码时接受标签切片或 Bitmap
 func WithBitmap(bitmap map[int]any) CodecOption {
 	return &bitmapOption{bitmap}
 }
 
+// WithBitField 设置位图中的多比特子字段, 占用从 offset 位开始的 width 位, 解码到 Bitmap.Fields[label];
+// width 必须大于0且子字段不能超出64位
+func WithBitField(label any, offset, width int) CodecOption {
+	if width <= 0 || offset < 0 || offset+width > 64 {
+		panic(fmt.Sprintf("bit field %v: offset %d width %d out of range 0-64", label, offset, width))
+	}
+	return &bitFieldOption{bitField{label: label, offset: offset, width: width}}
+}
+
+// floatCodecOption 设置浮点数编解码器, 同时设置字段长度
+type floatCodecOption struct {
+	bits  int
+	order binary.ByteOrder
+}
+
+func (o *floatCodecOption) Apply(config *FieldCodecConfig) {
+	config.codec = NewCodecFloat(o.bits, o.order)
+	config.length = o.bits / 8
+}
+
+// timeCodecOption 设置时间编解码器, 同时设置字段长度
+type timeCodecOption struct {
+	format TimeFormat
+	order  binary.ByteOrder
+}
+
+func (o *timeCodecOption) Apply(config *FieldCodecConfig) {
+	codec := NewCodecTime(o.format, o.order, nil)
+	config.codec = codec
+	config.length = codec.defaultLength()
+}
+
+type timeZoneOption struct {
+	location *time.Location
+}
+
+func (o *timeZoneOption) Apply(config *FieldCodecConfig) {
+	if codec, ok := config.codec.(*CodecTime); ok && o.location != nil {
+		codec.location = o.location
+	}
+}
+
+type baseYearOption struct {
+	year int
+}
+
+func (o *baseYearOption) Apply(config *FieldCodecConfig) {
+	if codec, ok := config.codec.(*CodecTime); ok {
+		codec.baseYear = o.year
+	}
+}
+
+type bcdScaleOption struct {
+	scale int
+}
+
+func (o *bcdScaleOption) Apply(config *FieldCodecConfig) {
+	codec, ok := config.codec.(*CodecBCD)
+	if !ok {
+		codec = NewCodecBCD(DefaultOrder())
+		config.codec = codec
+	}
+	codec.scale = max(o.scale, 0)
+}
+
+type paddingOption struct {
+	pad  byte
+	side PadSide
+}
+
+func (o *paddingOption) Apply(config *FieldCodecConfig) {
+	var codec *CodecString
+	switch c := config.codec.(type) {
+	case *CodecString:
+		codec = c
+	case *CodecASCII:
+		codec = &c.CodecString
+	default:
+		codec = NewCodecString(CharsetASCII, 0x00, PadRight)
+		config.codec = codec
+	}
+	codec.pad, codec.side = o.pad, o.side
+}
+
+type hexCaseOption struct {
+	lower bool
+}
+
+func (o *hexCaseOption) Apply(config *FieldCodecConfig) {
+	codec, ok := config.codec.(*CodecHEX)
+	if !ok {
+		codec = NewCodecHEX(DefaultOrder(), false)
+		config.codec = codec
+	}
+	codec.lower = o.lower
+}
+
+type signOption struct {
+	unsigned bool
+}
+
+func (o *signOption) Apply(config *FieldCodecConfig) {
+	codec, ok := config.codec.(*CodecBIN)
+	if !ok {
+		codec = NewCodecBIN(DefaultOrder())
+		config.codec = codec
+	}
+	codec.unsigned = o.unsigned
+}
+
 type lengthOption struct {
 	length int
 }
@@ -173,8 +395,9 @@ func (o *offsetOption) Apply(config *FieldCodecConfig) {
 }
 
 type enumOption struct {
-	other string
-	enum  map[int]any
+	other  string
+	enum   map[int]any
+	strict bool
 }
 
 func (o *enumOption) Apply(config *FieldCodecConfig) {
@@ -182,6 +405,19 @@ func (o *enumOption) Apply(config *FieldCodecConfig) {
 		config.explainConfig = &ExplainConfig{}
 	}
 	config.explainConfig.enum = o.enum
+	config.explainConfig.other = o.other
+	config.explainConfig.strictEnum = o.strict
+}
+
+type bitFieldOption struct {
+	field bitField
+}
+
+func (o *bitFieldOption) Apply(config *FieldCodecConfig) {
+	if config.explainConfig == nil {
+		config.explainConfig = &ExplainConfig{}
+	}
+	config.explainConfig.bitFields = append(config.explainConfig.bitFields, o.field)
 }
 
 type bitmapOption struct {
@@ -15,12 +15,16 @@
 package rot
 
 import (
+	"bufio"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"sync"
+	"time"
 )
 
 var (
@@ -42,7 +46,9 @@ func DefaultOrder() binary.ByteOrder {
 func NewProtocolBuilder() *ProtocolBuilder {
 	return &ProtocolBuilder{
 		du: &ProtocolDataUnit{
-			elements: make([]ProtocolElement, 0, 3),
+			elements:     make([]ProtocolElement, 0, 3),
+			logger:       discardLogger,
+			maxFrameSize: bufio.MaxScanTokenSize,
 		},
 	}
 }
@@ -110,6 +116,82 @@ func (duBuilder *ProtocolBuilder) HandleFuncWithParse(fc FunctionCode, handler H
 	return duBuilder
 }
 
+// HandleDefault 注册兜底处理函数, 处理未配置处理函数的功能码(如厂商扩展帧),
+// 可使用 IgnoreUnknownFunction、LogUnknownFunction 或 LogUnknownFunctionTo; 未注册时返回 ErrUnknownFunction
+func (duBuilder *ProtocolBuilder) HandleDefault(handler DefaultHandler) *ProtocolBuilder {
+	duBuilder.du.defaultHandler = handler
+	return duBuilder
+}
+
+// Use 注册全局中间件, 作用于所有功能码的处理函数(包括兜底处理函数), 先注册的在最外层
+func (duBuilder *ProtocolBuilder) Use(middlewares ...Middleware) *ProtocolBuilder {
+	duBuilder.du.middlewares = append(duBuilder.du.middlewares, middlewares...)
+	return duBuilder
+}
+
+// UseFor 注册仅作用于指定功能码的中间件, 在全局中间件之内执行
+func (duBuilder *ProtocolBuilder) UseFor(fc FunctionCode, middlewares ...Middleware) *ProtocolBuilder {
+	if duBuilder.du.codeMiddleware == nil {
+		duBuilder.du.codeMiddleware = make(map[FunctionCode][]Middleware)
+	}
+	duBuilder.du.codeMiddleware[fc] = append(duBuilder.du.codeMiddleware[fc], middlewares...)
+	return duBuilder
+}
+
+// SetLogger 设置日志记录器, 默认不输出任何日志; 每个连接会派生携带远端地址和设备标识的子记录器
+func (duBuilder *ProtocolBuilder) SetLogger(logger *slog.Logger) *ProtocolBuilder {
+	if logger == nil {
+		logger = discardLogger
+	}
+	duBuilder.du.logger = logger
+	return duBuilder
+}
+
+// SetMetrics 设置指标收集器, 通过 Metrics.Handler 暴露
+func (duBuilder *ProtocolBuilder) SetMetrics(metrics *Metrics) *ProtocolBuilder {
+	duBuilder.du.metrics = metrics
+	return duBuilder
+}
+
+// SetDispatch 启用异步处理模式: 读取协程只负责解码, 处理函数在有界的工作协程池中执行,
+// 同一会话(或同一会话的同一功能码)的帧按到达顺序处理. 所有会话共享同一个工作协程池
+func (duBuilder *ProtocolBuilder) SetDispatch(config DispatchConfig) *ProtocolBuilder {
+	duBuilder.du.dispatcher = newDispatcher(config)
+	return duBuilder
+}
+
+// SetWriteQueue 设置每个会话每个优先级的发送队列长度和队列满时的策略, 默认64和 QueueBlock
+func (duBuilder *ProtocolBuilder) SetWriteQueue(size int, policy QueueFullPolicy) *ProtocolBuilder {
+	duBuilder.du.writeQueueSize = size
+	duBuilder.du.writeQueuePolicy = policy
+	return duBuilder
+}
+
+// SetMaxFrameSize 设置分帧(Split、SplitFunc)时长度元素允许声明的最大长度, 默认 bufio.MaxScanTokenSize;
+// 超过时起始符被视为垃圾数据, 不再等待更多数据
+func (duBuilder *ProtocolBuilder) SetMaxFrameSize(size int) *ProtocolBuilder {
+	duBuilder.du.maxFrameSize = size
+	return duBuilder
+}
+
+// SetErrorPolicy 设置帧级错误的处理策略, 未设置时所有帧级错误都会使Serve返回
+func (duBuilder *ProtocolBuilder) SetErrorPolicy(policy ErrorPolicy) *ProtocolBuilder {
+	duBuilder.du.errorPolicy = policy
+	return duBuilder
+}
+
+// SetNack 设置错误策略为ActionNack时回复的NACK帧
+func (duBuilder *ProtocolBuilder) SetNack(nack NackFunc) *ProtocolBuilder {
+	duBuilder.du.nack = nack
+	return duBuilder
+}
+
+// OnFrameError 设置帧级错误回调, 在错误策略执行前调用
+func (duBuilder *ProtocolBuilder) OnFrameError(f func(fe *FrameError)) *ProtocolBuilder {
+	duBuilder.du.onFrameError = f
+	return duBuilder
+}
+
 // Build 构建协议数据单元
 func (duBuilder *ProtocolBuilder) Build() (Protocol, error) {
 	// 添加协议元素验证
@@ -128,33 +210,87 @@ func (duBuilder *ProtocolBuilder) Build() (Protocol, error) {
 		return nil, errors.New("最后一个协议元素必须是校验码")
 	}
 
-	fmt.Println("协议元素组成部分：")
-	fmt.Println("------------------------------------------------------")
-	fmt.Printf("元素名称\t元素类型\t元素长度\t默认值\n")
 	//todo 起始码+长度码 的长度
 	for index, element := range duBuilder.du.elements {
 		element.SetIndex(index)
-		fmt.Printf("%s\t%v\t\t%d\t\t%#x\n", element.GetName(), element.Type(), element.SelfLength(), element.DefaultValue())
+		duBuilder.du.logger.Debug("协议元素", "index", index, "name", element.GetName(), "type", element.Type(),
+			"length", element.SelfLength(), "default", hexBytes(element.DefaultValue()))
 	}
-	fmt.Println("------------------------------------------------------")
+	// 负载元素最后处理, 保证处理函数只会收到头部和校验码均已通过的数据单元;
+	// 序列号在校验通过后检查, 损坏的数据单元不会更新会话的序列号
+	dealOrder := make([]ProtocolElement, 0, len(duBuilder.du.elements))
+	var serials, payloads []ProtocolElement
+	for _, element := range duBuilder.du.elements {
+		switch element.Type() {
+		case Payload:
+			payloads = append(payloads, element)
+		case SerialNumber:
+			serials = append(serials, element)
+		default:
+			dealOrder = append(dealOrder, element)
+		}
+	}
+	dealOrder = append(dealOrder, serials...)
+	duBuilder.du.dealOrder = append(dealOrder, payloads...)
 	return duBuilder.du, nil
 }
 
 // Protocol 协议接口
 type Protocol interface {
 	AddHandler(fc FunctionCode, f *FunctionHandler)
-	Serve(ctx context.Context, conn net.Conn)
+	// Serve 循环读取并处理数据单元, 直到上下文取消、连接关闭或出现错误, 返回值总是 *ServeError
+	Serve(ctx context.Context, conn net.Conn) error
+	// ServeStream 处理任意字节流(如串口、管道), 返回值总是 *ServeError
+	ServeStream(ctx context.Context, rwc io.ReadWriteCloser) error
+	// ServePacket 处理数据报连接(如UDP), 每个数据报一个数据单元, 回复发送到来源地址, 返回值总是 *ServeError
+	ServePacket(ctx context.Context, pc net.PacketConn) error
+	// DecodeFrame 解码内存中的一个完整数据单元, 校验但不调用处理函数
+	DecodeFrame(data []byte) (*Frame, error)
+	// Split 返回data开头第一个完整数据单元的长度, 数据不足时返回0
+	Split(data []byte) (int, error)
+	// SplitFunc 生成可用于bufio.Scanner的分帧函数, 跳过垃圾数据
+	SplitFunc() bufio.SplitFunc
 }
 
-var _ Protocol = (*ProtocolDataUnit)(nil)
+// sessionServer 能在开始读取前交出会话的协议, Server 和 Client 借此登记会话
+type sessionServer interface {
+	serveConn(ctx context.Context, conn net.Conn, onSession func(*Session)) error
+}
+
+var (
+	_ Protocol      = (*ProtocolDataUnit)(nil)
+	_ sessionServer = (*ProtocolDataUnit)(nil)
+)
+
+// serveWithSession 处理连接并在开始读取前调用onSession;
+// 其他 Protocol 实现不提供会话, 此时直接调用 Serve 且不调用onSession
+func serveWithSession(ctx context.Context, p Protocol, conn net.Conn, onSession func(*Session)) error {
+	if ss, ok := p.(sessionServer); ok {
+		return ss.serveConn(ctx, conn, onSession)
+	}
+	return p.Serve(ctx, conn)
+}
 
 // ProtocolDataUnit 协议数据单元, 保存所有协议的上下文信息
 type ProtocolDataUnit struct {
-	counts     uint64
-	cryptLib   map[int]Cipher
-	conn       net.Conn
-	elements   []ProtocolElement
-	handlerMap map[FunctionCode]*FunctionHandler
+	cryptLib       map[int]Cipher
+	logger         *slog.Logger
+	metrics        *Metrics
+	elements       []ProtocolElement
+	dealOrder      []ProtocolElement
+	handlerMap     map[FunctionCode]*FunctionHandler
+	defaultHandler DefaultHandler
+	middlewares    []Middleware
+	codeMiddleware map[FunctionCode][]Middleware
+
+	dispatcher       *dispatcher
+	writeQueueSize   int
+	maxFrameSize     int
+	writeQueuePolicy QueueFullPolicy
+
+	errorPolicy  ErrorPolicy
+	nack         NackFunc
+	onFrameError func(fe *FrameError)
 }
 
 // GetElementByIndex 通过索引获取ProtocolElement
@@ -180,6 +316,16 @@ func (pdu *ProtocolDataUnit) GetAllElements() []ProtocolElement {
 	return pdu.elements
 }
 
+// Logger 返回协议的日志记录器
+func (pdu *ProtocolDataUnit) Logger() *slog.Logger {
+	return pdu.logger
+}
+
+// Metrics 返回指标收集器
+func (pdu *ProtocolDataUnit) Metrics() *Metrics {
+	return pdu.metrics
+}
+
 // AddCrypt 添加加密算法
 func (pdu *ProtocolDataUnit) AddCrypt(cryptFlag int, crypt Cipher) {
 	if pdu.cryptLib == nil {
@@ -188,15 +334,13 @@ func (pdu *ProtocolDataUnit) AddCrypt(cryptFlag int, crypt Cipher) {
 	pdu.cryptLib[cryptFlag] = crypt
 }
 
-// Decrypt 解密数据
+// Decrypt 解密数据, 未配置该加密标识的算法时返回 ErrDecryptFailed
 func (pdu *ProtocolDataUnit) Decrypt(cryptFlag int, src []byte) ([]byte, error) {
-	if pdu.cryptLib == nil {
-		return nil, errors.New("未配置加密算法")
-	}
-	if _, ok := pdu.cryptLib[cryptFlag]; !ok {
-		panic(fmt.Sprintf("未配置加密算法 %d", cryptFlag))
+	cipher, ok := pdu.cryptLib[cryptFlag]
+	if !ok {
+		return nil, fmt.Errorf("未配置加密算法 %d: %w", cryptFlag, ErrDecryptFailed)
 	}
-	return pdu.cryptLib[cryptFlag].Decrypt(src)
+	return cipher.Decrypt(src)
 }
 
 // AddHandler 添加处理函数
@@ -207,43 +351,76 @@ func (pdu *ProtocolDataUnit) AddHandler(fc FunctionCode, f *FunctionHandler) {
 	pdu.handlerMap[fc] = f
 }
 
-// DoHandle 执行处理函数
+// DoHandle 执行处理函数, 依次经过全局中间件和功能码中间件; 不处于会话中, 处理函数上下文中没有连接和会话
 func (pdu *ProtocolDataUnit) DoHandle(code FunctionCode, payload []byte) error {
-	if handler, ok := pdu.handlerMap[code]; !ok {
-		return errors.New("未配置处理函数")
+	return pdu.doHandle(nil, &HandlerContext{Code: code, Payload: payload})
+}
+
+// doHandle 查找处理函数并经过中间件执行, acc用于计算错误偏移, 可以为nil
+func (pdu *ProtocolDataUnit) doHandle(acc ProtocolDataUnitAccessor, hc *HandlerContext) error {
+	var h Handler
+	if handler, ok := pdu.handlerMap[hc.Code]; !ok {
+		if pdu.defaultHandler == nil {
+			element := pdu.GetElementByType(Function)
+			return newProtocolError(ErrUnknownFunction, element, acc, nil, []byte{byte(hc.Code)}, nil)
+		}
+		h = func(hc *HandlerContext) error {
+			return pdu.defaultHandler(hc.Code, hc.Payload)
+		}
 	} else {
-		return handler.Handle(payload)
+		h = handler.Handle
 	}
+	start := time.Now()
+	err := chain(h, pdu.middlewares, pdu.codeMiddleware[hc.Code])(hc)
+	pdu.metrics.observeHandler(hc.Code, time.Since(start))
+	return err
 }
 
+// aLongTimeAgo 过去的时间点, 设置为读超时可立即打断阻塞中的读取
+var aLongTimeAgo = time.Unix(1, 0)
+
 // Serve 处理连接
-func (pdu *ProtocolDataUnit) Serve(ctx context.Context, conn net.Conn) {
-	pdu.conn = conn
+func (pdu *ProtocolDataUnit) Serve(ctx context.Context, conn net.Conn) error {
+	return pdu.serveConn(ctx, conn, nil)
+}
+
+// serveConn 处理连接, onSession在开始读取前调用, 可用于设置设备标识和登记会话
+func (pdu *ProtocolDataUnit) serveConn(ctx context.Context, conn net.Conn, onSession func(*Session)) error {
+	session := newSession(conn, pdu)
+	if onSession != nil {
+		onSession(session)
+	}
+	pdu.metrics.sessionOpened()
+	defer pdu.metrics.sessionClosed()
+	// 结束前写出发送队列中剩余的帧
+	defer session.close()
+	state := newDecodeState(ctx, pdu, session, NewFrameReader(conn))
+	defer state.reader.Release()
+	// 上下文取消时设置过去的读超时, 使阻塞在读取中的元素立即返回; 不支持读超时的连接只能关闭
+	stop := context.AfterFunc(ctx, func() {
+		if err := conn.SetReadDeadline(aLongTimeAgo); err != nil {
+			conn.Close()
+		}
+	})
+	defer stop()
+	err := state.serveFrames()
+	// 异步处理模式下等待该会话已读取的帧处理完, 处理函数的错误优先于它引起的读取错误
+	state.pending.Wait()
+	if asyncErr := state.asyncError(); asyncErr != nil {
+		return asyncErr
+	}
+	return err
+}
+
+// serveFrames 循环读取并处理数据单元, 直到上下文取消或出现错误
+func (st *decodeState) serveFrames() error {
 	for {
-		select {
-		case <-ctx.Done():
+		if st.ctx.Err() != nil {
 			//停止读取
-			return
-		default:
-			fmt.Printf("[第%v个数据单元解析开始]\n", pdu.counts)
-			//第一遍遍历elements, 读取一个完整的数据单元
-			for _, element := range pdu.elements {
-				err := element.Preprocess(conn, element, pdu)
-				if err != nil {
-					fmt.Println("数据预处理失败:", err)
-					return
-				}
-			}
-			for _, element := range pdu.elements {
-				err := element.Deal(pdu)
-				if err != nil {
-					fmt.Println("数据解析失败:", err)
-					return
-				}
-			}
-			fmt.Printf("[第%v个数据单元解析完成]\n", pdu.counts)
-			fmt.Println()
-			pdu.counts++
+			return &ServeError{Reason: StopCanceled, Err: context.Cause(st.ctx)}
+		}
+		if err := st.serveFrame(); err != nil {
+			return err
 		}
 	}
 }
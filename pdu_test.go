@@ -0,0 +1,529 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/longan55/Rules-over-TCP/fake"
+)
+
+// newTestBuilder 构建测试用协议: 起始符(0x68) 长度(1) 加密标识 功能码 负载 校验码(ModBusCRC)
+func newTestBuilder() *ProtocolBuilder {
+	builder := NewProtocolBuilder()
+	builder.AddCryptConfig(NewCryptConfig())
+	builder.AddElement(NewStarter([]byte{0x68})).
+		AddElement(NewDataLen(1)).
+		AddElement(NewCyptoFlag()).
+		AddElement(NewFuncCode()).
+		AddElement(NewPayload()).
+		AddElement(NewCheckSum(0, 2))
+	return builder
+}
+
+func TestServe_CancelInterruptsRead(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- protocol.Serve(ctx, server)
+	}()
+
+	// 等待 Serve 阻塞在起始符的读取中
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		var serveErr *ServeError
+		if !errors.As(err, &serveErr) {
+			t.Fatalf("want *ServeError, got %T: %v", err, err)
+		}
+		if serveErr.Reason != StopCanceled {
+			t.Fatalf("want reason %v, got %v", StopCanceled, serveErr.Reason)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("want context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve was not interrupted by context cancellation")
+	}
+}
+
+func TestServe_StopReasons(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 无数据可读: 对端关闭
+	conn := fake.NewFakeConn()
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+		t.Fatalf("want reason %v, got %v", StopEOF, err)
+	}
+
+	// 起始符错误: 协议错误
+	conn = fake.NewFakeConn()
+	conn.SetData([]byte{0x69, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	err = protocol.Serve(context.Background(), conn)
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopProtocol {
+		t.Fatalf("want reason %v, got %v", StopProtocol, err)
+	}
+
+	// 读超时
+	server, client := net.Pipe()
+	defer client.Close()
+	server.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	err = protocol.Serve(context.Background(), server)
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopTimeout {
+		t.Fatalf("want reason %v, got %v", StopTimeout, err)
+	}
+}
+
+func TestServe_ErrorPolicy(t *testing.T) {
+	var handled []string
+	var frameErrors []*FrameError
+	builder := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			handled = append(handled, hc.Parsed["ascii"].Explained.(string))
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionEscalate, map[FrameErrorClass]ErrorAction{
+			ClassChecksum:        ActionSkip,
+			ClassUnknownFunction: ActionNack,
+		})).
+		SetNack(func(fe *FrameError) []byte {
+			return []byte{0x68, 0xEE}
+		}).
+		OnFrameError(func(fe *FrameError) {
+			frameErrors = append(frameErrors, fe)
+		})
+	protocol, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	// 校验码错误
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x00, 0x00})
+	// 未配置处理函数的功能码
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61})
+	// 正常帧
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+		t.Fatalf("want reason %v, got %v", StopEOF, err)
+	}
+	if len(handled) != 1 || handled[0] != "0123" {
+		t.Fatalf("handler should only see the valid frame, got %v", handled)
+	}
+	if len(frameErrors) != 2 {
+		t.Fatalf("want 2 frame errors, got %d", len(frameErrors))
+	}
+	if frameErrors[0].Class != ClassChecksum || frameErrors[0].Element.Type() != Checksum {
+		t.Fatalf("unexpected first frame error: %v", frameErrors[0])
+	}
+	if frameErrors[0].Raw[0] != 0x68 || len(frameErrors[0].Raw) != 10 {
+		t.Fatalf("unexpected raw frame: % X", frameErrors[0].Raw)
+	}
+	if frameErrors[1].Class != ClassUnknownFunction || !errors.Is(frameErrors[1], ErrUnknownFunction) {
+		t.Fatalf("unexpected second frame error: %v", frameErrors[1])
+	}
+	if written := conn.GetWrittenData(); len(written) != 2 || written[1] != 0xEE {
+		t.Fatalf("want NACK written, got % X", written)
+	}
+}
+
+func TestServe_ResyncAfterBadPreamble(t *testing.T) {
+	var handled []string
+	var frameErrors []*FrameError
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			handled = append(handled, hc.Parsed["ascii"].Explained.(string))
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionSkip, nil)).
+		OnFrameError(func(fe *FrameError) {
+			frameErrors = append(frameErrors, fe)
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	// 垃圾数据的"帧长度"覆盖了后面正常帧的开头, 跳过后从下一个起始符重新读取
+	conn.SetData([]byte{0xAA, 0x02})
+	conn.SetData(testFrame(0x03, []byte("0123")))
+	conn.SetData([]byte{0x00, 0x02})
+	conn.SetData(testFrame(0x03, []byte("4567")))
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+		t.Fatalf("want reason %v, got %v", StopEOF, err)
+	}
+	if fmt.Sprint(handled) != "[0123 4567]" {
+		t.Fatalf("want both frames handled after resync, got %v", handled)
+	}
+	if len(frameErrors) != 2 || !errors.Is(frameErrors[0], ErrBadPreamble) || !errors.Is(frameErrors[1], ErrBadPreamble) {
+		t.Fatalf("want 2 bad preamble errors, got %v", frameErrors)
+	}
+}
+
+func TestServe_ProtocolError(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x00, 0x00})
+	err = protocol.Serve(context.Background(), conn)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("want ErrChecksumMismatch, got %v", err)
+	}
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) {
+		t.Fatalf("want *ProtocolError, got %T", err)
+	}
+	if protocolErr.Element.Type() != Checksum || protocolErr.Offset != 8 {
+		t.Fatalf("unexpected element or offset: %v", protocolErr)
+	}
+	if !bytes.Equal(protocolErr.Expected, []byte{0x4f, 0xa1}) || !bytes.Equal(protocolErr.Actual, []byte{0x00, 0x00}) {
+		t.Fatalf("unexpected expected/actual bytes: %v", protocolErr)
+	}
+
+	conn = fake.NewFakeConn()
+	conn.SetData([]byte{0x69, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	err = protocol.Serve(context.Background(), conn)
+	if !errors.Is(err, ErrBadPreamble) || errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("want ErrBadPreamble, got %v", err)
+	}
+	if !errors.As(err, &protocolErr) || protocolErr.Offset != 0 || protocolErr.Actual[0] != 0x69 {
+		t.Fatalf("unexpected protocol error: %v", err)
+	}
+}
+
+func TestServe_HandleDefault(t *testing.T) {
+	var codes []FunctionCode
+	var payloads [][]byte
+	protocol, err := newTestBuilder().
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			codes = append(codes, code)
+			payloads = append(payloads, payload)
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	err = protocol.Serve(context.Background(), conn)
+	var serveErr *ServeError
+	if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+		t.Fatalf("want reason %v, got %v", StopEOF, err)
+	}
+	if len(codes) != 2 || codes[0] != 0x04 || codes[1] != 0x03 {
+		t.Fatalf("unexpected codes: %v", codes)
+	}
+	if !bytes.Equal(payloads[0], []byte{0x30, 0x31, 0x32, 0x33}) {
+		t.Fatalf("unexpected payload: % X", payloads[0])
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	for _, handler := range []DefaultHandler{IgnoreUnknownFunction, LogUnknownFunction, LogUnknownFunctionTo(logger)} {
+		protocol, err = newTestBuilder().HandleDefault(handler).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn = fake.NewFakeConn()
+		conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61})
+		err = protocol.Serve(context.Background(), conn)
+		if !errors.As(err, &serveErr) || serveErr.Reason != StopEOF {
+			t.Fatalf("want reason %v, got %v", StopEOF, err)
+		}
+	}
+	if !strings.Contains(buf.String(), "code=04") {
+		t.Fatalf("unknown function code not logged: %s", buf.String())
+	}
+}
+
+func TestServe_Middleware(t *testing.T) {
+	var trace []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(hc *HandlerContext) error {
+				trace = append(trace, fmt.Sprintf("%s>%#x", name, byte(hc.Code)))
+				err := next(hc)
+				trace = append(trace, fmt.Sprintf("%s<%#x", name, byte(hc.Code)))
+				return err
+			}
+		}
+	}
+	var frameErrors []*FrameError
+	protocol, err := newTestBuilder().
+		Use(record("global"), Recover()).
+		UseFor(FunctionCode(0x03), record("code3")).
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			trace = append(trace, "handler:"+hc.Parsed["ascii"].Explained.(string))
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			panic("boom")
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionSkip, nil)).
+		OnFrameError(func(fe *FrameError) {
+			frameErrors = append(frameErrors, fe)
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0xfa, 0x61})
+	// 负载解析失败同样经过中间件
+	conn.SetData(testFrame(0x03, []byte("012")))
+	protocol.Serve(context.Background(), conn)
+
+	want := []string{"global>0x3", "code3>0x3", "handler:0123", "code3<0x3", "global<0x3", "global>0x4", "global<0x4",
+		"global>0x3", "code3>0x3", "code3<0x3", "global<0x3"}
+	if fmt.Sprint(trace) != fmt.Sprint(want) {
+		t.Fatalf("want trace %v, got %v", want, trace)
+	}
+	if len(frameErrors) != 2 || frameErrors[0].Class != ClassHandler {
+		t.Fatalf("recovered panic should be reported as handler error, got %v", frameErrors)
+	}
+	if !errors.Is(frameErrors[1], ErrLengthMismatch) {
+		t.Fatalf("want parse error, got %v", frameErrors[1])
+	}
+}
+
+func TestServe_SessionLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	protocol, err := newTestBuilder().
+		SetLogger(logger).
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			return nil
+		}).
+		UseFor(FunctionCode(0x03), func(next Handler) Handler {
+			return func(hc *HandlerContext) error {
+				hc.Session.SetDeviceID("pile-01")
+				return next(hc)
+			}
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x04, 0x30, 0x31, 0x32, 0x33, 0x00, 0x00})
+	protocol.Serve(context.Background(), conn)
+
+	out := buf.String()
+	if !strings.Contains(out, "remote=127.0.0.1:8081") {
+		t.Fatalf("log should carry remote address:\n%s", out)
+	}
+	if !strings.Contains(out, `level=WARN msg=数据解析失败 remote=127.0.0.1:8081 device=pile-01`) {
+		t.Fatalf("failure should be logged at warn with device id:\n%s", out)
+	}
+	if !strings.Contains(out, `msg=帧负载 remote=127.0.0.1:8081 src="30 31 32 33"`) {
+		t.Fatalf("frame dump should be logged at debug:\n%s", out)
+	}
+}
+
+func TestServe_FrameRetained(t *testing.T) {
+	var frames []*Frame
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			frames = append(frames, hc.Frame)
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1})
+	conn.SetData([]byte{0x68, 0x06, 0x00, 0x03, 0x34, 0x35, 0x36, 0x37, 0x0c, 0x53})
+	protocol.Serve(context.Background(), conn)
+
+	if len(frames) != 2 {
+		t.Fatalf("want 2 frames, got %d", len(frames))
+	}
+	// 第一个Frame在第二个数据单元到达后内容不变
+	first := frames[0]
+	if !bytes.Equal(first.Payload(), []byte("0123")) || first.Raw()[4] != 0x30 {
+		t.Fatalf("retained frame was overwritten: % X", first.Raw())
+	}
+	if field, ok := first.Field("ascii"); !ok || field.Explained != "0123" || !bytes.Equal(field.Bytes, []byte("0123")) {
+		t.Fatalf("unexpected field: %+v", field)
+	}
+	if length, ok := first.Header(Length); !ok || length.Value != 6 || !bytes.Equal(length.Raw, []byte{0x06}) {
+		t.Fatalf("unexpected length header: %+v", length)
+	}
+	if code, ok := first.Header(Function); !ok || code.Value != FunctionCode(0x03) {
+		t.Fatalf("unexpected function header: %+v", code)
+	}
+	if first.Code() != 0x03 || !bytes.Equal(first.Checksum(), []byte{0x4f, 0xa1}) || first.ReceivedAt().IsZero() {
+		t.Fatalf("unexpected frame: code %#x checksum % X", first.Code(), first.Checksum())
+	}
+	if frames[1].Fields()["ascii"].Explained != "4567" {
+		t.Fatalf("unexpected second frame fields: %v", frames[1].Fields())
+	}
+}
+
+func TestServe_ConcurrentSessions(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[string]int{}
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			mu.Lock()
+			handled[hc.Parsed["ascii"].Explained.(string)]++
+			mu.Unlock()
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := [][]byte{
+		{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1},
+		{0x68, 0x06, 0x00, 0x03, 0x34, 0x35, 0x36, 0x37, 0x0c, 0x53},
+	}
+	var wg sync.WaitGroup
+	for _, frame := range frames {
+		conn := fake.NewFakeConn()
+		for range 100 {
+			conn.SetData(frame)
+		}
+		wg.Go(func() {
+			protocol.Serve(context.Background(), conn)
+		})
+	}
+	wg.Wait()
+	if handled["0123"] != 100 || handled["4567"] != 100 {
+		t.Fatalf("unexpected handled counts: %v", handled)
+	}
+}
+
+func TestServe_SerialNumber(t *testing.T) {
+	builder := NewProtocolBuilder()
+	builder.AddCryptConfig(NewCryptConfig())
+	builder.AddElement(NewStarter([]byte{0x68})).
+		AddElement(NewDataLen(1)).
+		AddElement(NewSerialNumber()).
+		AddElement(NewCyptoFlag()).
+		AddElement(NewFuncCode()).
+		AddElement(NewPayload()).
+		AddElement(NewCheckSum(0, 2))
+	var (
+		handled     []byte
+		frameErrors []*FrameError
+	)
+	protocol, err := builder.
+		HandleDefault(func(code FunctionCode, payload []byte) error {
+			handled = append(handled, payload[0])
+			return nil
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionSkip, nil)).
+		OnFrameError(func(fe *FrameError) {
+			frameErrors = append(frameErrors, fe)
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := func(sn uint16, tag byte) []byte {
+		// 长度只计加密标识、功能码和负载
+		f := []byte{0x68, 0x03, byte(sn >> 8), byte(sn), 0x00, 0x03, tag}
+		return append(f, ModBusCRC(f[2:])...)
+	}
+
+	conn := fake.NewFakeConn()
+	// 序列号按16位回绕递增, 相同或回退的数据单元被跳过
+	conn.SetData(frame(0xfffe, 1))
+	conn.SetData(frame(0xffff, 2))
+	conn.SetData(frame(0xffff, 3))
+	conn.SetData(frame(0x0000, 4))
+	conn.SetData(frame(0xffff, 5))
+	conn.SetData(frame(0x0005, 6))
+	protocol.Serve(context.Background(), conn)
+
+	if !bytes.Equal(handled, []byte{1, 2, 4, 6}) {
+		t.Fatalf("unexpected handled frames %v", handled)
+	}
+	if len(frameErrors) != 2 || !errors.Is(frameErrors[0], ErrSerialNumber) || !errors.Is(frameErrors[1], ErrSerialNumber) {
+		t.Fatalf("want 2 serial number errors, got %v", frameErrors)
+	}
+
+	// 只解码单个数据单元时不检查序列号
+	for range 2 {
+		if _, err := protocol.DecodeFrame(frame(0x0001, 7)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDecrypt_Unconfigured(t *testing.T) {
+	pdu := &ProtocolDataUnit{}
+	if _, err := pdu.Decrypt(1, []byte{0x01}); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("no cipher: want ErrDecryptFailed, got %v", err)
+	}
+	pdu.AddCrypt(0, &CryptNothing{})
+	if _, err := pdu.Decrypt(1, []byte{0x01}); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("unknown flag: want ErrDecryptFailed, got %v", err)
+	}
+}
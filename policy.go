@@ -0,0 +1,116 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FrameErrorClass 帧级错误的分类
+type FrameErrorClass int
+
+const (
+	// 帧格式错误: 起始符、长度、加密标识等头部元素处理失败
+	ClassMalformed FrameErrorClass = iota
+	// 校验码不匹配
+	ClassChecksum
+	// 功能码未配置处理函数
+	ClassUnknownFunction
+	// 负载解析失败或处理函数返回错误
+	ClassHandler
+)
+
+func (c FrameErrorClass) String() string {
+	switch c {
+	case ClassMalformed:
+		return "malformed"
+	case ClassChecksum:
+		return "checksum"
+	case ClassUnknownFunction:
+		return "unknown_function"
+	case ClassHandler:
+		return "handler"
+	default:
+		return fmt.Sprintf("FrameErrorClass(%d)", int(c))
+	}
+}
+
+// ErrorAction 帧级错误的处理动作
+type ErrorAction int
+
+const (
+	// 向上抛出: Serve 返回错误, 连接交由调用方处理(默认)
+	ActionEscalate ErrorAction = iota
+	// 跳过该帧, 继续读取下一帧; 起始符错误时从下一个起始符重新开始读取
+	ActionSkip
+	// 回复 NACK 帧后继续读取下一帧, 未配置 NACK 时等同于跳过
+	ActionNack
+	// 关闭连接后返回
+	ActionClose
+)
+
+// FrameError 帧级错误, 帧已完整读取但在处理阶段失败
+type FrameError struct {
+	Class   FrameErrorClass
+	Element ProtocolElement // 处理失败的元素
+	Raw     []byte          // 完整的原始帧
+	Frame   *Frame          // 出错时已解码的内容, 字段可能不完整
+	Err     error
+}
+
+func (e *FrameError) Error() string {
+	if e.Element == nil {
+		// 数据报长度与数据单元不一致等错误不属于某个元素
+		return fmt.Sprintf("%s error: %v", e.Class, e.Err)
+	}
+	return fmt.Sprintf("%s error at element %s: %v", e.Class, e.Element.GetName(), e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy 错误策略, 根据帧级错误决定处理动作
+type ErrorPolicy func(fe *FrameError) ErrorAction
+
+// NewErrorPolicy 按错误分类创建错误策略, 未列出的分类使用 defaultAction
+func NewErrorPolicy(defaultAction ErrorAction, actions map[FrameErrorClass]ErrorAction) ErrorPolicy {
+	return func(fe *FrameError) ErrorAction {
+		if action, ok := actions[fe.Class]; ok {
+			return action
+		}
+		return defaultAction
+	}
+}
+
+// NackFunc 根据帧级错误生成需要回复的 NACK 帧
+type NackFunc func(fe *FrameError) []byte
+
+// classifyFrameError 根据失败元素和错误对帧级错误分类
+func classifyFrameError(element ProtocolElement, err error) FrameErrorClass {
+	switch {
+	case errors.Is(err, ErrUnknownFunction):
+		return ClassUnknownFunction
+	case errors.Is(err, ErrChecksumMismatch):
+		return ClassChecksum
+	case errors.Is(err, ErrBadPreamble), errors.Is(err, ErrDecryptFailed):
+		return ClassMalformed
+	case element != nil && element.Type() == Payload:
+		return ClassHandler
+	default:
+		return ClassMalformed
+	}
+}
@@ -16,14 +16,14 @@ package rot
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"net"
+	"log/slog"
 )
 
-// ProtocolElement 元素接口
+// ProtocolElement 元素接口, 元素只保存定义, 每个数据单元的原始字节和解析值由 ProtocolDataUnitAccessor 保存
 type ProtocolElement interface {
 	//获取元素的索引
 	GetIndex() int
@@ -33,24 +33,16 @@ type ProtocolElement interface {
 	GetName() string
 	//获取元素的类型
 	Type() ProtocolElementType
-	//获取元素的字节原始数据
-	Source() []byte
-	//设置元素的字节原始数据
-	SetSource(src []byte)
-	//获取元素解析后的实际值
-	RealValue() any
-	//设置元素解析后的实际值
-	SetRealValue(value any)
 	//获取元素的默认值
 	DefaultValue() []byte
 	//获取元素自身占用的字节长度
 	SelfLength() int
 	//获取元素的字节序
 	GetOrder() binary.ByteOrder
-	//预处理: 从conn中读取数据并解析，将字节数据和实际值填充到element中，pdu用于访问整个元素集合或协议的元数据
-	Preprocess(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error
-	//pdu提供对ProtocolDataUnit的访问
-	Deal(pdu ProtocolDataUnitAccessor) error
+	//预处理: 从帧读取器中读取数据并解析，将字节数据和实际值保存到pdu中，pdu用于访问当前数据单元和协议的元数据
+	Preprocess(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error
+	//处理: 校验或处理当前数据单元, pdu提供对当前数据单元的访问
+	Deal(element ProtocolElement, pdu ProtocolDataUnitAccessor) error
 	//获取校验和类型
 	ChecksumType() uint8
 }
@@ -74,7 +66,7 @@ const (
 	Checksum
 )
 
-// ProtocolDataUnitAccessor 提供对ProtocolDataUnit的访问接口
+// ProtocolDataUnitAccessor 提供对当前数据单元的访问接口
 type ProtocolDataUnitAccessor interface {
 	// GetElementByIndex 通过索引获取ProtocolElement
 	GetElementByIndex(index int) ProtocolElement
@@ -82,12 +74,26 @@ type ProtocolDataUnitAccessor interface {
 	GetElementByType(typ ProtocolElementType) ProtocolElement
 	// GetAllElements 获取所有ProtocolElement
 	GetAllElements() []ProtocolElement
+	// Source 获取元素在当前数据单元中的原始字节, 内容在下一个数据单元开始后失效
+	Source(element ProtocolElement) []byte
+	// SetSource 设置元素在当前数据单元中的原始字节
+	SetSource(element ProtocolElement, src []byte)
+	// RealValue 获取元素在当前数据单元中解析后的值
+	RealValue(element ProtocolElement) any
+	// SetRealValue 设置元素在当前数据单元中解析后的值
+	SetRealValue(element ProtocolElement, value any)
 	Decrypt(cryptFlag int, src []byte) ([]byte, error)
 	DoHandle(code FunctionCode, payload []byte) error
+	// Logger 返回当前会话的日志记录器
+	Logger() *slog.Logger
+	// RawFrame 返回当前数据单元已读取的全部原始字节, 内容在下一个数据单元开始后失效
+	RawFrame() []byte
+	// Metrics 返回指标收集器, 未配置时为nil(记录方法对nil安全)
+	Metrics() *Metrics
 }
 
 // PreprocessFunction 预处理函数
-type PreprocessFunction func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error
+type PreprocessFunction func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error
 
 // DealFunction 处理函数
 type DealFunction func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error
@@ -102,8 +108,6 @@ type ProtocolElementImpl struct {
 	name           string              //元素名字
 	selfLength     int                 //元素本身长度
 	defaultValue   []byte              //默认值
-	src            []byte              //元素的实际值(原始字节数据)
-	realValue      any                 //元素的实际值(解析后的值)
 	order          binary.ByteOrder    //大小端
 	start          uint8               //开始索引: 该元素影响的元素区域的第一个元素索引
 	end            uint8               //结束索引: 该元素影响的元素区域的最后一个元素索引
@@ -128,22 +132,6 @@ func (f *ProtocolElementImpl) Type() ProtocolElementType {
 	return f.Typ
 }
 
-func (f *ProtocolElementImpl) Source() []byte {
-	return f.src
-}
-
-func (f *ProtocolElementImpl) SetSource(src []byte) {
-	f.src = src
-}
-
-func (f *ProtocolElementImpl) RealValue() any {
-	return f.realValue
-}
-
-func (f *ProtocolElementImpl) SetRealValue(value any) {
-	f.realValue = value
-}
-
 func (f *ProtocolElementImpl) DefaultValue() []byte {
 	return f.defaultValue
 }
@@ -152,7 +140,11 @@ func (f *ProtocolElementImpl) SelfLength() int {
 	return f.selfLength
 }
 
+// GetOrder 返回元素的字节序, 未设置时使用默认字节序
 func (f *ProtocolElementImpl) GetOrder() binary.ByteOrder {
+	if f.order == nil {
+		return DefaultOrder()
+	}
 	return f.order
 }
 
@@ -160,13 +152,16 @@ func (f *ProtocolElementImpl) GetRange() (start, end uint8) {
 	return f.start, f.end
 }
 
-func (f *ProtocolElementImpl) Preprocess(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-	return f.PreprocessFunc(conn, element, pdu)
+func (f *ProtocolElementImpl) Preprocess(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+	if f.PreprocessFunc != nil {
+		return f.PreprocessFunc(r, element, pdu)
+	}
+	return nil
 }
 
-func (f *ProtocolElementImpl) Deal(pdu ProtocolDataUnitAccessor) error {
+func (f *ProtocolElementImpl) Deal(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
 	if f.DealFunc != nil {
-		return f.DealFunc(f, pdu)
+		return f.DealFunc(element, pdu)
 	}
 	return nil
 }
@@ -183,22 +178,22 @@ func NewStarter(start []byte) ProtocolElement {
 		defaultValue: start,
 		selfLength:   len(start),
 	}
-	// 起始符的预处理函数, 从conn中读取数据, 并将其添加到fullData中
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		buf := make([]byte, element.SelfLength())
-		_, err := io.ReadFull(conn, buf)
+	// 起始符的预处理函数, 从帧读取器中读取数据
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		fmt.Printf("起始符:\t\t\t[% #0X]\n", buf)
-		element.SetSource(buf)
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("起始符", "src", hexBytes(buf))
+		}
+		pdu.SetSource(element, buf)
 		return nil
 	}
 	// 起始符的处理函数, 验证起始符是否正确
 	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		if !bytes.Equal(element.Source(), element.DefaultValue()) {
-			return fmt.Errorf("起始符错误Need:%0X,But:%0X", element.DefaultValue(), element.Source())
+		if src := pdu.Source(element); !bytes.Equal(src, element.DefaultValue()) {
+			return newProtocolError(ErrBadPreamble, element, pdu, element.DefaultValue(), src, nil)
 		}
 		return nil
 	}
@@ -212,17 +207,17 @@ func NewDataLen(selfLength int) ProtocolElement {
 		defaultValue: nil,
 		selfLength:   selfLength,
 	}
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		buf := make([]byte, element.SelfLength())
-		_, err := io.ReadFull(conn, buf)
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		element.SetSource(buf)
-		length := Bin2Int(buf, element.GetOrder())
-		element.SetRealValue(length)
-		fmt.Printf("帧长度:\t\t\t[%d]\n", length)
+		pdu.SetSource(element, buf)
+		length := int(Bin2Uint(buf, element.GetOrder()))
+		pdu.SetRealValue(element, length)
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("帧长度", "length", length)
+		}
 		return nil
 	}
 	return element
@@ -235,24 +230,24 @@ func NewSerialNumber() ProtocolElement {
 		name:       "序 列 号 ",
 		selfLength: 2,
 	}
-	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		if pdu == nil {
-			return errors.New("数据为空")
-		}
-		serialdata := pdu.GetElementByIndex(element.GetIndex()).Source()
-		sn := Bin2Int(serialdata, element.GetOrder())
-		oldsn, ok := element.RealValue().(int16)
-		if !ok {
-			return fmt.Errorf("序列号错误Need:%d,But:%d", element.RealValue(), sn)
-		}
-		if sn < int(oldsn) {
-			return fmt.Errorf("序列号重复Latest:%d,But:%d", element.RealValue(), sn)
-		}
-		if sn == int(oldsn)+1 {
-			element.SetRealValue(int16(sn))
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
+		if err != nil {
+			return err
 		}
+		pdu.SetSource(element, buf)
+		pdu.SetRealValue(element, int(Bin2Uint(buf, element.GetOrder())))
 		return nil
 	}
+	// 序列号的处理函数, 同一会话中序列号相同或回退的数据单元视为重复
+	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		st, ok := pdu.(*decodeState)
+		if !ok || st.session == nil {
+			// 只解码单个数据单元时没有上一个序列号
+			return nil
+		}
+		return st.checkSerial(element)
+	}
 	return element
 }
 
@@ -264,33 +259,34 @@ func NewCyptoFlag() ProtocolElement {
 		defaultValue: []byte{0x01},
 		selfLength:   1,
 	}
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		buf := make([]byte, element.SelfLength())
-		_, err := io.ReadFull(conn, buf)
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		element.SetSource(buf)
-		flag := Bin2Int(buf, element.GetOrder())
-		element.SetRealValue(flag)
-		fmt.Printf("加密标识:\t\t[%#0X]\n", buf)
+		pdu.SetSource(element, buf)
+		flag := int(Bin2Uint(buf, element.GetOrder()))
+		pdu.SetRealValue(element, flag)
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("加密标识", "src", hexBytes(buf))
+		}
 		return nil
 	}
 	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
 		if pdu == nil {
-			return errors.New("数据为空")
+			return newProtocolError(ErrDecryptFailed, element, nil, nil, nil, errors.New("数据为空"))
 		}
-		if flag, ok := element.RealValue().(int); !ok {
-			return fmt.Errorf("加密标识类型错误")
+		if flag, ok := pdu.RealValue(element).(int); !ok {
+			return newProtocolError(ErrDecryptFailed, element, pdu, nil, pdu.Source(element), errors.New("加密标识类型错误"))
 		} else {
 			payloadElement := pdu.GetElementByType(Payload)
-			payload := payloadElement.Source()
+			payload := pdu.Source(payloadElement)
 			payload0, err := pdu.Decrypt(flag, payload)
 			if err != nil {
-				return err
+				pdu.Metrics().decryptFailed()
+				return newProtocolError(ErrDecryptFailed, element, pdu, nil, pdu.Source(element), err)
 			}
-			payloadElement.SetRealValue(payload0)
+			pdu.SetRealValue(payloadElement, payload0)
 		}
 		return nil
 	}
@@ -304,17 +300,17 @@ func NewFuncCode() ProtocolElement {
 		defaultValue: nil,
 		selfLength:   1,
 	}
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		buf := make([]byte, element.SelfLength())
-		_, err := io.ReadFull(conn, buf)
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		element.SetSource(buf)
-		functionCode := Bin2Int(buf, element.GetOrder())
-		element.SetRealValue(FunctionCode(functionCode))
-		fmt.Printf("功能码:\t\t\t[%#0X]\n", buf)
+		pdu.SetSource(element, buf)
+		functionCode := Bin2Uint(buf, element.GetOrder())
+		pdu.SetRealValue(element, FunctionCode(functionCode))
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("功能码", "src", hexBytes(buf))
+		}
 		return nil
 	}
 	return element
@@ -328,35 +324,44 @@ func NewPayload() ProtocolElement {
 		selfLength:   -1,
 	}
 	//读取负载
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
 		lengthElement := pdu.GetElementByType(Length)
 		if lengthElement == nil {
-			return errors.New("未找到Length元素")
+			return newProtocolError(ErrLengthMismatch, element, pdu, nil, nil, errors.New("未找到Length元素"))
 		}
-		length, ok := lengthElement.RealValue().(int)
+		length, ok := pdu.RealValue(lengthElement).(int)
 		if !ok {
-			return errors.New("Length元素值不是整数")
+			return newProtocolError(ErrLengthMismatch, lengthElement, pdu, nil, pdu.Source(lengthElement), errors.New("Length元素值不是整数"))
+		}
+		if length < 2 {
+			return newProtocolError(ErrLengthMismatch, lengthElement, pdu, nil, pdu.Source(lengthElement),
+				fmt.Errorf("帧长度%d小于加密标识和功能码的长度", length))
 		}
-		buf := make([]byte, length-2)
-		_, err := io.ReadFull(conn, buf)
+		buf, err := r.Next(length - 2)
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		element.SetSource(buf)
-		fmt.Printf("帧负载:\t\t\t[% #0X]\n", buf)
+		pdu.SetSource(element, buf)
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("帧负载", "src", hexBytes(buf))
+		}
 		return nil
 	}
 	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
 		functionCodeElement := pdu.GetElementByType(Function)
 		if functionCodeElement == nil {
-			return errors.New("未找到Function元素")
+			return newProtocolError(ErrUnknownFunction, element, pdu, nil, nil, errors.New("未找到Function元素"))
+		}
+		functionCode, ok := pdu.RealValue(functionCodeElement).(FunctionCode)
+		if !ok {
+			return newProtocolError(ErrUnknownFunction, functionCodeElement, pdu, nil, pdu.Source(functionCodeElement), errors.New("Function元素值不是整数"))
 		}
-		functionCode, ok := functionCodeElement.RealValue().(FunctionCode)
+		// 没有加密标识元素时负载未经解密
+		payload, ok := pdu.RealValue(element).([]byte)
 		if !ok {
-			return errors.New("Function元素值不是整数")
+			payload = pdu.Source(element)
 		}
-		return pdu.DoHandle(functionCode, element.RealValue().([]byte))
+		return pdu.DoHandle(functionCode, payload)
 	}
 	return element
 }
@@ -369,40 +374,46 @@ func NewCheckSum(checksumType uint8, selfLength int) ProtocolElement {
 		selfLength:   selfLength,
 		checksumType: checksumType,
 	}
-	element.PreprocessFunc = func(conn net.Conn, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		buf := make([]byte, element.SelfLength())
-		_, err := io.ReadFull(conn, buf)
+	element.PreprocessFunc = func(r *FrameReader, element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
+		buf, err := r.Next(element.SelfLength())
 		if err != nil {
-			fmt.Println("读取数据失败:", err)
 			return err
 		}
-		element.SetSource(buf)
-		fmt.Printf("校 验 码:\t\t[% #0X]\n", buf)
+		pdu.SetSource(element, buf)
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("校验码", "src", hexBytes(buf))
+		}
 		return nil
 	}
 	element.DealFunc = func(element ProtocolElement, pdu ProtocolDataUnitAccessor) error {
-		checksum0 := element.Source()
+		checksum0 := pdu.Source(element)
 		if len(checksum0) == 0 {
-			return errors.New("校验码为空")
+			return newProtocolError(ErrChecksumMismatch, element, pdu, nil, nil, errors.New("校验码为空"))
 		}
-		//将各切片连接为一个切片
-		var full []byte
-		for i := 2; i < element.GetIndex(); i++ {
+		//校验范围: 从索引为2的元素到校验码之前, 在帧缓冲区中是连续的
+		start, end := 0, 0
+		for i := 0; i < element.GetIndex(); i++ {
 			e := pdu.GetElementByIndex(i)
 			if e == nil {
-				return fmt.Errorf("未找到索引为%d的元素", i)
+				return newProtocolError(ErrChecksumMismatch, element, pdu, nil, nil, fmt.Errorf("未找到索引为%d的元素", i))
 			}
-			src := e.Source()
-			if src == nil {
-				return fmt.Errorf("索引为%d的元素源数据为空", i)
+			src := pdu.Source(e)
+			if i >= 2 && src == nil {
+				return newProtocolError(ErrChecksumMismatch, element, pdu, nil, nil, fmt.Errorf("索引为%d的元素源数据为空", i))
 			}
-			full = append(full, src...)
+			if i < 2 {
+				start += len(src)
+			}
+			end += len(src)
 		}
-		checksum := CheckSum(element.ChecksumType(), full)
+		checksum := CheckSum(element.ChecksumType(), pdu.RawFrame()[start:end])
 		if !bytes.Equal(checksum, checksum0) {
-			return fmt.Errorf("校验码错误Need:%0X,But:%0X", checksum, checksum0)
+			pdu.Metrics().checksumFailed()
+			return newProtocolError(ErrChecksumMismatch, element, pdu, checksum, checksum0, nil)
+		}
+		if logger := pdu.Logger(); logger.Enabled(context.Background(), slog.LevelDebug) {
+			logger.Debug("校验通过", "type", element.ChecksumType(), "checksum", hexBytes(checksum))
 		}
-		fmt.Printf("校验码类型:%d,计算校验码:% #0X,校验通过\n", element.ChecksumType(), checksum)
 		return nil
 	}
 	return element
@@ -0,0 +1,160 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// readBufferSize 每个会话的读缓冲区大小
+	readBufferSize = 4096
+	// frameBufferSize 帧缓冲区的初始容量, 超出时自动扩容
+	frameBufferSize = 512
+	// maxPooledFrameBufferSize 归还到池中的帧缓冲区的最大容量, 读取超大帧后扩容的缓冲区直接丢弃
+	maxPooledFrameBufferSize = 64 * 1024
+)
+
+var frameBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, frameBufferSize)
+		return &buf
+	},
+}
+
+// FrameReader 帧读取器, 从带缓冲的数据源中将一个数据单元的字节连续读入同一个缓冲区,
+// 元素通过 Next 获得指向该缓冲区的切片, 下一个数据单元开始后切片内容失效.
+// 由 NewFrameReaderBytes 创建时作为字节游标, 直接返回指向原数据的切片
+type FrameReader struct {
+	r   *bufio.Reader
+	buf *[]byte
+
+	// 重新同步时退回的字节, 在r之前读取
+	pending []byte
+
+	// 字节游标模式
+	data []byte
+	pos  int
+}
+
+// NewFrameReader 创建帧读取器, r不是*bufio.Reader时自动包装
+func NewFrameReader(r io.Reader) *FrameReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, readBufferSize)
+	}
+	return &FrameReader{
+		r:   br,
+		buf: frameBufferPool.Get().(*[]byte),
+	}
+}
+
+// NewFrameReaderBytes 创建内存中数据的帧读取器, 不复制数据
+func NewFrameReaderBytes(data []byte) *FrameReader {
+	return &FrameReader{data: data}
+}
+
+// Next 读取n个字节追加到帧缓冲区, 返回指向缓冲区的切片
+func (fr *FrameReader) Next(n int) ([]byte, error) {
+	if fr.r == nil {
+		return fr.nextBytes(n)
+	}
+	buf := *fr.buf
+	start := len(buf)
+	if cap(buf)-start < n {
+		grown := make([]byte, start, 2*cap(buf)+n)
+		copy(grown, buf)
+		buf = grown
+	}
+	buf = buf[:start+n]
+	*fr.buf = buf
+	k := copy(buf[start:], fr.pending)
+	fr.pending = fr.pending[k:]
+	if _, err := io.ReadFull(fr.r, buf[start+k:]); err != nil {
+		*fr.buf = buf[:start]
+		return nil, err
+	}
+	return buf[start:], nil
+}
+
+// nextBytes 字节游标模式下的 Next, 数据不足时与io.ReadFull返回相同的错误
+func (fr *FrameReader) nextBytes(n int) ([]byte, error) {
+	if len(fr.data)-fr.pos < n {
+		if fr.pos == len(fr.data) {
+			return nil, io.EOF
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	fr.pos += n
+	return fr.data[fr.pos-n : fr.pos], nil
+}
+
+// skipTo 丢弃当前数据单元第一个字节之后、prefix之前的数据, 使下一次读取从prefix开始;
+// 当前数据单元已读取的其余字节会被重新读取. 字节游标模式下每段数据只有一个数据单元, 不需要重新同步
+func (fr *FrameReader) skipTo(prefix []byte) error {
+	if fr.r == nil || len(prefix) == 0 {
+		return nil
+	}
+	if buf := *fr.buf; len(buf) > 1 {
+		fr.pending = append(bytes.Clone(buf[1:]), fr.pending...)
+	}
+	fr.Reset()
+	for {
+		if i := bytes.Index(fr.pending, prefix); i >= 0 {
+			fr.pending = fr.pending[i:]
+			return nil
+		}
+		// 保留末尾可能是prefix开头的字节, 再读取一个字节继续查找
+		fr.pending = fr.pending[len(fr.pending)-partialPrefix(fr.pending, prefix):]
+		b, err := fr.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		fr.pending = append(fr.pending, b)
+	}
+}
+
+// Bytes 返回当前数据单元已读取的全部字节
+func (fr *FrameReader) Bytes() []byte {
+	if fr.r == nil {
+		return fr.data[:fr.pos]
+	}
+	return *fr.buf
+}
+
+// Reset 开始读取新的数据单元, 复用帧缓冲区; 字节游标模式下从当前位置继续
+func (fr *FrameReader) Reset() {
+	if fr.r == nil {
+		fr.data = fr.data[fr.pos:]
+		fr.pos = 0
+		return
+	}
+	*fr.buf = (*fr.buf)[:0]
+}
+
+// Release 归还帧缓冲区, 之后不能再使用该读取器; 容量超过 maxPooledFrameBufferSize 的缓冲区不归还
+func (fr *FrameReader) Release() {
+	if fr.buf == nil {
+		return
+	}
+	fr.Reset()
+	if cap(*fr.buf) <= maxPooledFrameBufferSize {
+		frameBufferPool.Put(fr.buf)
+	}
+	fr.buf = nil
+}
@@ -0,0 +1,134 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/longan55/Rules-over-TCP/fake"
+)
+
+// countingConn 统计Read调用次数, 每次Read对应真实连接上的一次系统调用
+type countingConn struct {
+	*fake.FakeConn
+	reads int
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	c.reads++
+	return c.FakeConn.Read(b)
+}
+
+// BenchmarkServe 每次迭代处理10k个数据单元, 报告每帧的读调用次数和每秒处理的帧数
+func BenchmarkServe(b *testing.B) {
+	const frames = 10000
+	frame := []byte{0x68, 0x0F, 0x00, 0x01, 0x7F, 0xFF, 0xFF, 0xFF, 0x80, 0x00, 0x00, 0x00, 0x12, 0x34, 0x12, 0x34, 0x01, 0x1a, 0x40}
+	data := make([]byte, 0, len(frame)*frames)
+	for range frames {
+		data = append(data, frame...)
+	}
+	protocol, err := newTestBuilder().
+		HandleDefault(IgnoreUnknownFunction).
+		Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	reads := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		conn := &countingConn{FakeConn: fake.NewFakeConn()}
+		conn.SetData(data)
+		protocol.Serve(context.Background(), conn)
+		reads += conn.reads
+	}
+	b.ReportMetric(float64(reads)/float64(b.N*frames), "reads/frame")
+	b.ReportMetric(float64(b.N*frames)/b.Elapsed().Seconds(), "frames/s")
+}
+
+func TestFrameReader_Contiguous(t *testing.T) {
+	conn := fake.NewFakeConn()
+	data := make([]byte, 1500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	conn.SetData(data)
+
+	reader := NewFrameReader(conn)
+	defer reader.Release()
+	head, err := reader.Next(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超出初始容量时扩容, 已返回的切片内容保持不变
+	body, err := reader.Next(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head[1] != 0x01 || body[0] != 0x02 || body[999] != byte(1001%256) {
+		t.Fatalf("unexpected content: % X ... % X", head, body[:4])
+	}
+	if raw := reader.Bytes(); len(raw) != 1002 || raw[1001] != byte(1001%256) {
+		t.Fatalf("frame should be contiguous, got len %d", len(raw))
+	}
+
+	reader.Reset()
+	if _, err := reader.Next(1000); err == nil {
+		t.Fatal("want error on short read")
+	}
+	if len(reader.Bytes()) != 0 {
+		t.Fatalf("failed read should not extend the frame, got %d bytes", len(reader.Bytes()))
+	}
+}
+
+func TestFrameReader_SkipTo(t *testing.T) {
+	conn := fake.NewFakeConn()
+	conn.SetData([]byte{0xAA, 0x68, 0x01, 0x68, 0x68, 0x02, 0x03})
+	reader := NewFrameReader(conn)
+	defer reader.Release()
+	if _, err := reader.Next(3); err != nil {
+		t.Fatal(err)
+	}
+	// 已读取字节中第一个字节之后的数据也参与查找, 跨越读取边界的起始符同样能找到
+	if err := reader.skipTo([]byte{0x68, 0x68}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.Bytes()) != 0 {
+		t.Fatalf("skipTo should start a new frame, got % X", reader.Bytes())
+	}
+	b, err := reader.Next(4)
+	if err != nil || string(b) != string([]byte{0x68, 0x68, 0x02, 0x03}) {
+		t.Fatalf("got % X, %v", b, err)
+	}
+}
+
+func TestFrameReader_ReleaseDropsLargeBuffer(t *testing.T) {
+	conn := fake.NewFakeConn()
+	conn.SetData(make([]byte, maxPooledFrameBufferSize+1))
+	reader := NewFrameReader(conn)
+	if _, err := reader.Next(maxPooledFrameBufferSize + 1); err != nil {
+		t.Fatal(err)
+	}
+	buf := reader.buf
+	reader.Release()
+	// 超大的缓冲区不归还到池中, 池中取出的缓冲区不会是它
+	for range 10 {
+		if got := frameBufferPool.Get().(*[]byte); got == buf {
+			t.Fatal("oversized frame buffer returned to the pool")
+		}
+	}
+}
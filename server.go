@@ -0,0 +1,260 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultHandshakeTimeout TLS握手的默认超时时间
+const defaultHandshakeTimeout = 10 * time.Second
+
+// IdentityFunc 从已验证的客户端证书中获取设备标识, 返回错误时拒绝该连接
+type IdentityFunc func(cert *x509.Certificate) (string, error)
+
+// CertificateIdentity 默认的设备标识: 证书的CN, CN为空时依次使用第一个DNS SAN和URI SAN
+func CertificateIdentity(cert *x509.Certificate) (string, error) {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName, nil
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0], nil
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String(), nil
+	default:
+		return "", errors.New("客户端证书中没有CN或SAN")
+	}
+}
+
+// NewMutualTLSConfig 创建要求并验证客户端证书的TLS配置, clientCAs为签发设备证书的CA
+func NewMutualTLSConfig(cert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// Server 服务端, 接受连接并使用同一个协议处理, 按设备标识登记会话.
+// 配置TLS后, 客户端证书(CN/SAN)在处理函数执行前成为会话的设备标识
+type Server struct {
+	protocol         Protocol
+	logger           *slog.Logger
+	tlsConfig        *tls.Config
+	identity         IdentityFunc
+	handshakeTimeout time.Duration
+
+	mu       sync.RWMutex
+	sessions map[*Session]struct{}
+	devices  map[string]*Session
+}
+
+// NewServer 创建服务端
+func NewServer(protocol Protocol) *Server {
+	return &Server{
+		protocol:         protocol,
+		logger:           discardLogger,
+		identity:         CertificateIdentity,
+		handshakeTimeout: defaultHandshakeTimeout,
+		sessions:         make(map[*Session]struct{}),
+		devices:          make(map[string]*Session),
+	}
+}
+
+// SetLogger 设置服务端的日志记录器, 记录接受连接和TLS握手失败; 会话的日志由协议的记录器输出
+func (s *Server) SetLogger(logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = discardLogger
+	}
+	s.logger = logger
+	return s
+}
+
+// SetTLSConfig 设置TLS配置, 接受的连接完成TLS握手后才开始处理; Serve应传入普通的监听器
+func (s *Server) SetTLSConfig(config *tls.Config) *Server {
+	s.tlsConfig = config
+	return s
+}
+
+// SetIdentity 设置从客户端证书获取设备标识的方法, 默认为 CertificateIdentity
+func (s *Server) SetIdentity(identity IdentityFunc) *Server {
+	s.identity = identity
+	return s
+}
+
+// SetHandshakeTimeout 设置TLS握手的超时时间, 默认10秒
+func (s *Server) SetHandshakeTimeout(timeout time.Duration) *Server {
+	s.handshakeTimeout = timeout
+	return s
+}
+
+// ListenAndServe 监听TCP地址并处理连接, 直到上下文取消
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve 接受连接并为每个连接启动一个会话, 直到上下文取消或监听器出错.
+// 返回前关闭监听器并等待所有会话结束
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		ln.Close()
+	})
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var tempDelay time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			// 临时错误(如文件描述符耗尽)退避后重试, 与 net/http.Server 相同
+			if te, ok := err.(interface{ Temporary() bool }); ok && te.Temporary() {
+				tempDelay = min(max(tempDelay*2, 5*time.Millisecond), time.Second)
+				s.logger.Warn("accept error, retrying", "error", err, "delay", tempDelay)
+				select {
+				case <-time.After(tempDelay):
+					continue
+				case <-ctx.Done():
+					return context.Cause(ctx)
+				}
+			}
+			ln.Close()
+			return err
+		}
+		tempDelay = 0
+		wg.Go(func() {
+			s.serveConn(ctx, conn)
+		})
+	}
+}
+
+// serveConn 完成TLS握手后处理连接, 结束时关闭连接
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var deviceID string
+	if s.tlsConfig != nil {
+		tlsConn := tls.Server(conn, s.tlsConfig)
+		id, err := s.handshake(ctx, tlsConn)
+		if err != nil {
+			s.logger.Warn("TLS握手失败", "remote", conn.RemoteAddr().String(), "err", err)
+			return
+		}
+		conn, deviceID = tlsConn, id
+	}
+	var session *Session
+	err := serveWithSession(ctx, s.protocol, conn, func(ss *Session) {
+		session = ss
+		if deviceID != "" {
+			// 证书确定的设备标识不能被处理函数修改, 避免设备冒用其他设备的登记
+			ss.lockDeviceID(deviceID)
+		}
+		s.register(ss)
+	})
+	s.unregister(session)
+	session.Logger().Debug("会话结束", "err", err)
+}
+
+// handshake 完成TLS握手, 有客户端证书时返回其设备标识
+func (s *Server) handshake(ctx context.Context, conn *tls.Conn) (string, error) {
+	if s.handshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.handshakeTimeout)
+		defer cancel()
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", nil
+	}
+	id, err := s.identity(certs[0])
+	if err != nil {
+		return "", fmt.Errorf("客户端证书 %q 无法映射为设备标识: %w", certs[0].Subject, err)
+	}
+	return id, nil
+}
+
+// register 登记会话, 并在设备标识变化时更新登记
+func (s *Server) register(session *Session) {
+	session.mu.Lock()
+	session.onDeviceID = s.rebind
+	session.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session] = struct{}{}
+	if id := session.DeviceID(); id != "" {
+		s.devices[id] = session
+	}
+}
+
+// rebind 设备标识变化后更新登记, 同一设备标识的新会话替换旧会话
+func (s *Server) rebind(session *Session, old string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[session]; !ok {
+		return
+	}
+	if s.devices[old] == session {
+		delete(s.devices, old)
+	}
+	if id := session.DeviceID(); id != "" {
+		s.devices[id] = session
+	}
+}
+
+// unregister 取消会话的登记
+func (s *Server) unregister(session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, session)
+	if id := session.DeviceID(); s.devices[id] == session {
+		delete(s.devices, id)
+	}
+}
+
+// Session 按设备标识查找当前连接的会话
+func (s *Server) Session(deviceID string) (*Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.devices[deviceID]
+	return session, ok
+}
+
+// Sessions 返回所有当前连接的会话, 包括尚未设置设备标识的会话
+func (s *Server) Sessions() []*Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
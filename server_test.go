@@ -0,0 +1,283 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCA 测试用的本地CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rot test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发证书, template中的序列号、有效期和密钥用途由此设置
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "rot server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+	}, x509.ExtKeyUsageServerAuth)
+
+	type seen struct {
+		device string
+		cn     string
+	}
+	handled := make(chan seen, 4)
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			state, ok := hc.Session.TLSState()
+			if !ok {
+				t.Error("session is not a TLS session")
+			}
+			handled <- seen{device: hc.Session.DeviceID(), cn: state.PeerCertificates[0].Subject.CommonName}
+			return nil
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(protocol).SetTLSConfig(NewMutualTLSConfig(serverCert, ca.pool))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, ln)
+	}()
+
+	dial := func(certs ...tls.Certificate) (*tls.Conn, error) {
+		return tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+			RootCAs:      ca.pool,
+			Certificates: certs,
+		})
+	}
+	frame := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+
+	// CN作为设备标识
+	conn, err := dial(ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "pile-001"}}, x509.ExtKeyUsageClientAuth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-handled; got.device != "pile-001" || got.cn != "pile-001" {
+		t.Fatalf("unexpected identity: %+v", got)
+	}
+	session, ok := server.Session("pile-001")
+	if !ok || session.DeviceID() != "pile-001" {
+		t.Fatal("session not registered by device identity")
+	}
+	// 证书确定的设备标识不能被修改为其他设备的标识
+	if err := session.SetDeviceID("pile-002.example"); !errors.Is(err, ErrDeviceIDLocked) {
+		t.Fatalf("want ErrDeviceIDLocked, got %v", err)
+	}
+	if err := session.SetDeviceID("pile-001"); err != nil {
+		t.Fatalf("setting the certificate identity again: %v", err)
+	}
+	if got, ok := server.Session("pile-001"); !ok || got != session || session.DeviceID() != "pile-001" {
+		t.Fatal("certificate identity changed after SetDeviceID")
+	}
+
+	// CN为空时使用DNS SAN
+	sanConn, err := dial(ca.issue(t, &x509.Certificate{DNSNames: []string{"pile-002.example"}}, x509.ExtKeyUsageClientAuth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sanConn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-handled; got.device != "pile-002.example" {
+		t.Fatalf("unexpected identity: %+v", got)
+	}
+	if n := len(server.Sessions()); n != 2 {
+		t.Fatalf("want 2 sessions, got %d", n)
+	}
+	if got, _ := server.Session("pile-002.example"); got == session {
+		t.Fatal("session took over another device's registration")
+	}
+
+	// 断开后取消登记
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := server.Session("pile-001"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session not unregistered after disconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 没有客户端证书或证书不是由信任的CA签发时握手失败
+	for _, certs := range [][]tls.Certificate{
+		nil,
+		{newTestCA(t).issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "rogue"}}, x509.ExtKeyUsageClientAuth)},
+	} {
+		bad, err := dial(certs...)
+		if err == nil {
+			// TLS 1.3中客户端在读取时才能得知握手失败
+			bad.SetReadDeadline(time.Now().Add(time.Second))
+			_, err = bad.Read(make([]byte, 1))
+			bad.Close()
+		}
+		var netErr net.Error
+		if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatalf("connection without a trusted client certificate was accepted: %v", err)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("want context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server.Serve did not stop after cancellation")
+	}
+	sanConn.Close()
+	if len(server.Sessions()) != 0 {
+		t.Fatalf("sessions left after Serve returned: %d", len(server.Sessions()))
+	}
+	select {
+	case got := <-handled:
+		t.Fatalf("unexpected handler call: %+v", got)
+	default:
+	}
+}
+
+// tempErr 临时的 Accept 错误
+type tempErr struct{}
+
+func (tempErr) Error() string   { return "temporary accept error" }
+func (tempErr) Timeout() bool   { return false }
+func (tempErr) Temporary() bool { return true }
+
+// flakyListener 前几次 Accept 返回临时错误
+type flakyListener struct {
+	net.Listener
+	failures int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, tempErr{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServer_AcceptTemporaryError(t *testing.T) {
+	protocol, err := newTestBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(protocol)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, &flakyListener{Listener: ln, failures: 3})
+	}()
+
+	// 临时错误后继续接受连接
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(server.Sessions()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not accepted after temporary errors")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
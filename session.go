@@ -0,0 +1,146 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// ErrDeviceIDLocked 会话的设备标识来自客户端证书, 不能修改为其他标识
+var ErrDeviceIDLocked = errors.New("设备标识已由客户端证书确定")
+
+// discardLogger 默认的空日志记录器
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// Session 连接会话, 保存单个连接的上下文信息
+type Session struct {
+	conn    net.Conn
+	metrics *Metrics
+
+	mu       sync.RWMutex
+	base     *slog.Logger // 带远端地址的日志记录器
+	logger   *slog.Logger // 带远端地址和设备标识的日志记录器
+	deviceID string
+	// deviceIDLocked 设备标识来自客户端证书, 不能再修改
+	deviceIDLocked bool
+	// onDeviceID 设备标识变化时调用, 用于更新服务端的会话登记
+	onDeviceID func(s *Session, old string)
+	// onFrame 在处理函数之前接收数据单元和解析负载的错误, 返回true表示已处理(如客户端的Call应答), 开始读取后不再修改
+	onFrame func(frame *Frame, err error) bool
+
+	queue *writeQueue // 发送队列, 由发送协程整帧写出
+}
+
+func newSession(conn net.Conn, pdu *ProtocolDataUnit) *Session {
+	base := pdu.logger.With("remote", conn.RemoteAddr().String())
+	s := &Session{
+		conn:    conn,
+		metrics: pdu.metrics,
+		base:    base,
+		logger:  base,
+		queue:   newWriteQueue(pdu.writeQueueSize, pdu.writeQueuePolicy),
+	}
+	go s.writeLoop()
+	return s
+}
+
+// Conn 返回会话的连接
+func (s *Session) Conn() net.Conn {
+	return s.conn
+}
+
+// RemoteAddr 返回对端地址
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Logger 返回会话的日志记录器, 携带远端地址和设备标识
+func (s *Session) Logger() *slog.Logger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.logger
+}
+
+// DeviceID 返回设备标识, 未设置时为空
+func (s *Session) DeviceID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deviceID
+}
+
+// SetDeviceID 设置设备标识(如登录帧中的桩编号), 之后的日志都会携带该标识.
+// 设备标识来自客户端证书时不能修改, 与证书标识不同时返回 ErrDeviceIDLocked
+func (s *Session) SetDeviceID(id string) error {
+	s.mu.Lock()
+	old := s.deviceID
+	if s.deviceIDLocked && id != old {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: 证书标识 %q, 请求的标识 %q", ErrDeviceIDLocked, old, id)
+	}
+	s.deviceID = id
+	s.logger = s.base.With("device", id)
+	onDeviceID := s.onDeviceID
+	s.mu.Unlock()
+	if onDeviceID != nil && old != id {
+		onDeviceID(s, old)
+	}
+	return nil
+}
+
+// lockDeviceID 设置来自客户端证书的设备标识, 之后不能再修改
+func (s *Session) lockDeviceID(id string) {
+	s.SetDeviceID(id)
+	s.mu.Lock()
+	s.deviceIDLocked = true
+	s.mu.Unlock()
+}
+
+// TLSState 返回TLS连接的状态, 不是TLS连接时第二个返回值为false
+func (s *Session) TLSState() (tls.ConnectionState, bool) {
+	if conn, ok := s.conn.(*tls.Conn); ok {
+		return conn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+// Send 以普通优先级将一个完整的帧放入发送队列, code用于指标统计; 队列满时按协议配置的策略阻塞或丢弃.
+// 帧由会话的发送协程整帧写出, 返回后frame可以复用; 写出失败后连接被关闭, 之后的Send返回该错误
+func (s *Session) Send(code FunctionCode, frame []byte) error {
+	return s.SendContext(context.Background(), PriorityNormal, code, frame)
+}
+
+// SendContext 以指定优先级发送一个完整的帧, 队列满且策略为 QueueBlock 时可通过ctx取消等待
+func (s *Session) SendContext(ctx context.Context, priority Priority, code FunctionCode, frame []byte) error {
+	return s.enqueue(ctx, priority, outFrame{code: code, data: bytes.Clone(frame), counted: true})
+}
+
+// write 以高优先级发送不属于任何功能码的数据(如NACK)
+func (s *Session) write(b []byte) error {
+	return s.enqueue(context.Background(), PriorityHigh, outFrame{data: bytes.Clone(b)})
+}
+
+// hexBytes 日志中以十六进制输出的字节切片
+type hexBytes []byte
+
+func (b hexBytes) LogValue() slog.Value {
+	return slog.StringValue(fmt.Sprintf("% X", []byte(b)))
+}
@@ -0,0 +1,90 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+)
+
+// SplitFunc 根据协议定义生成bufio.SplitFunc, 可用于bufio.Scanner或第三方事件循环的分帧:
+// 按起始符定位帧首, 按长度元素确定帧长, 校验码不通过、声明的长度超过最大帧长或起始符之前的字节视为垃圾数据跳过,
+// 数据不足时请求更多数据, 读到末尾时丢弃不完整的帧
+func (pdu *ProtocolDataUnit) SplitFunc() bufio.SplitFunc {
+	preamble := pdu.elements[0].DefaultValue()
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		from := 0
+		for {
+			i := bytes.Index(data[from:], preamble)
+			if i < 0 {
+				if atEOF {
+					return len(data), nil, nil
+				}
+				// 保留末尾可能是起始符前缀的字节
+				return len(data) - partialPrefix(data, preamble), nil, nil
+			}
+			start := from + i
+			n, err := pdu.Split(data[start:])
+			if err != nil {
+				from = start + 1
+				continue
+			}
+			if n == 0 {
+				if atEOF {
+					from = start + 1
+					continue
+				}
+				// 丢弃帧首之前的垃圾数据, 等待更多数据
+				return start, nil, nil
+			}
+			frame := data[start : start+n]
+			if !pdu.verify(frame) {
+				from = start + 1
+				continue
+			}
+			return start + n, frame, nil
+		}
+	}
+}
+
+// verify 检查完整帧的起始符和校验码
+func (pdu *ProtocolDataUnit) verify(frame []byte) bool {
+	state := newDecodeState(context.Background(), pdu, nil, NewFrameReaderBytes(frame))
+	// 试探性的校验不记录指标, 看起来像起始符的垃圾数据不计入校验失败
+	state.probe = true
+	if err := state.readFrame(); err != nil {
+		return false
+	}
+	for _, element := range state.elements {
+		if element.Type() != Preamble && element.Type() != Checksum {
+			continue
+		}
+		if err := element.Deal(element, state); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// partialPrefix 返回data末尾与prefix开头重合的最大长度(小于len(prefix))
+func partialPrefix(data, prefix []byte) int {
+	for k := min(len(prefix)-1, len(data)); k > 0; k-- {
+		if bytes.Equal(data[len(data)-k:], prefix[:k]) {
+			return k
+		}
+	}
+	return 0
+}
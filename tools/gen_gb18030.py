@@ -0,0 +1,42 @@
+#!/usr/bin/env python3
+#
+# Copyright 2025-2026 longan55 or authors.
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      https://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""生成 charset_gb18030.bin: 使用Python标准库的gb18030编解码器解码双字节区.
+
+首字节0x81-0xFE、尾字节0x40-0xFE, 每项为大端序的两字节Unicode码点,
+无法解码或码点超出BMP的组合写0. 用法: python3 tools/gen_gb18030.py <输出文件>
+"""
+
+import hashlib
+import sys
+
+
+def main():
+    out = bytearray()
+    for lead in range(0x81, 0xFF):
+        for trail in range(0x40, 0xFF):
+            try:
+                s = bytes([lead, trail]).decode("gb18030")
+            except UnicodeDecodeError:
+                s = ""
+            cp = ord(s) if len(s) == 1 and ord(s) <= 0xFFFF else 0
+            out += cp.to_bytes(2, "big")
+    with open(sys.argv[1], "wb") as f:
+        f.write(out)
+    print(len(out), hashlib.sha256(out).hexdigest())
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,206 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// maxDatagramSize UDP数据报的最大长度
+	maxDatagramSize = 65535
+	// packetSessionIdle 数据报会话的空闲时间, 超过后丢弃会话(包括设备标识)
+	packetSessionIdle = 5 * time.Minute
+)
+
+// ServeStream 处理任意字节流, 如串口、管道、pty设备.
+// rwc是net.Conn时与Serve相同; 否则支持SetReadDeadline的数据源(如*os.File管道)通过读超时打断读取,
+// 不支持的数据源在上下文取消时会被关闭
+func (pdu *ProtocolDataUnit) ServeStream(ctx context.Context, rwc io.ReadWriteCloser) error {
+	conn, ok := rwc.(net.Conn)
+	if !ok {
+		conn = &streamConn{ReadWriteCloser: rwc}
+	}
+	return pdu.Serve(ctx, conn)
+}
+
+// ServePacket 处理数据报连接(如UDP), 每个数据报恰好包含一个数据单元, 回复发送到数据报的来源地址.
+// 每个来源地址对应一个会话, 空闲超过5分钟后丢弃. 帧级错误同样按错误策略处理,
+// 但ActionEscalate和ActionClose只丢弃该来源的会话, 不会停止服务;
+// 直到上下文取消或读取出错才返回, 返回值总是 *ServeError
+func (pdu *ProtocolDataUnit) ServePacket(ctx context.Context, pc net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() {
+		pc.SetReadDeadline(aLongTimeAgo)
+	})
+	defer stop()
+	states := make(map[string]*decodeState)
+	defer func() {
+		for _, state := range states {
+			state.session.close()
+			pdu.metrics.sessionClosed()
+		}
+	}()
+	buf := make([]byte, maxDatagramSize)
+	lastSweep := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return &ServeError{Reason: StopCanceled, Err: context.Cause(ctx)}
+		}
+		n, addr, err := pc.ReadFrom(buf)
+		if n > 0 {
+			key := addr.String()
+			state, ok := states[key]
+			if !ok {
+				session := newSession(&packetConn{pc: pc, addr: addr}, pdu)
+				state = newDecodeState(ctx, pdu, session, nil)
+				states[key] = state
+				pdu.metrics.sessionOpened()
+			}
+			if serveErr := state.serveDatagram(buf[:n]); serveErr != nil {
+				state.session.Logger().Warn("丢弃数据报会话", "err", serveErr)
+				state.session.close()
+				delete(states, key)
+				pdu.metrics.sessionClosed()
+			}
+		}
+		if err != nil {
+			serveErr := readStopError(ctx, err)
+			if serveErr.Reason != StopCanceled {
+				pdu.logger.Warn("数据报读取失败", "reason", serveErr.Reason, "err", err)
+			}
+			return serveErr
+		}
+		if now := time.Now(); now.Sub(lastSweep) > time.Minute {
+			lastSweep = now
+			for key, state := range states {
+				if now.Sub(state.receivedAt) > packetSessionIdle {
+					state.session.close()
+					delete(states, key)
+					pdu.metrics.sessionClosed()
+				}
+			}
+		}
+	}
+}
+
+// serveDatagram 处理一个数据报, 返回非nil表示应丢弃该会话
+func (st *decodeState) serveDatagram(data []byte) error {
+	// 发送失败或处理函数要求停止的会话不再可用, 丢弃后由下一个数据报重新创建
+	if err := st.session.queue.failed(); err != nil {
+		return &ServeError{Reason: StopIO, Err: err}
+	}
+	if err := st.asyncError(); err != nil {
+		return err
+	}
+	err := st.readExact(data)
+	// 出错时readFrame可能未设置接收时间, 会话空闲时间从最后一个数据报开始计算
+	st.receivedAt = time.Now()
+	if err != nil {
+		st.session.Logger().Warn("数据报不是完整的数据单元", "err", err)
+		return st.handleFrameError(nil, err)
+	}
+	if st.metrics != nil {
+		st.metrics.frameReceived(st.functionCode(), len(data))
+	}
+	if element, err := st.dealFrame(); err != nil {
+		st.session.Logger().Warn("数据解析失败", "element", element.GetName(), "err", err)
+		return st.handleFrameError(element, err)
+	}
+	st.counts++
+	return nil
+}
+
+// streamConn 将任意字节流适配为net.Conn
+type streamConn struct {
+	io.ReadWriteCloser
+}
+
+func (c *streamConn) LocalAddr() net.Addr {
+	return streamAddr("local")
+}
+
+// RemoteAddr 数据源有Name方法(如*os.File)时返回其名称
+func (c *streamConn) RemoteAddr() net.Addr {
+	if named, ok := c.ReadWriteCloser.(interface{ Name() string }); ok {
+		return streamAddr(named.Name())
+	}
+	return streamAddr("stream")
+}
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetDeadline(time.Time) error }); ok {
+		return d.SetDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
+
+// streamAddr 字节流的地址
+type streamAddr string
+
+func (a streamAddr) Network() string { return "stream" }
+func (a streamAddr) String() string  { return string(a) }
+
+// errPacketRead 数据报会话的连接不能读取, 数据由ServePacket分发
+var errPacketRead = errors.New("数据报会话不支持读取")
+
+// packetConn 数据报会话的连接, 写入的数据发送到来源地址; 关闭不影响底层的PacketConn
+type packetConn struct {
+	pc   net.PacketConn
+	addr net.Addr
+}
+
+func (c *packetConn) Read(b []byte) (int, error) {
+	return 0, errPacketRead
+}
+
+func (c *packetConn) Write(b []byte) (int, error) {
+	return c.pc.WriteTo(b, c.addr)
+}
+
+func (c *packetConn) Close() error {
+	return nil
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return c.pc.LocalAddr()
+}
+
+func (c *packetConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// 底层PacketConn由所有会话共享, 不能为单个会话设置超时
+func (c *packetConn) SetDeadline(t time.Time) error      { return os.ErrNoDeadline }
+func (c *packetConn) SetReadDeadline(t time.Time) error  { return os.ErrNoDeadline }
+func (c *packetConn) SetWriteDeadline(t time.Time) error { return os.ErrNoDeadline }
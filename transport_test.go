@@ -0,0 +1,185 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipeStream 由两个io.Pipe组成的双向字节流, 不支持读超时
+type pipeStream struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p *pipeStream) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+func TestServePacket(t *testing.T) {
+	frame := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	var (
+		mu          sync.Mutex
+		frameErrors []string
+	)
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			// 同一来源地址的数据报属于同一个会话
+			if hc.Session.DeviceID() == "" {
+				hc.Session.SetDeviceID(hc.Conn.RemoteAddr().String())
+			}
+			_, err := hc.Conn.Write([]byte(hc.Session.DeviceID()))
+			return err
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		SetErrorPolicy(NewErrorPolicy(ActionSkip, nil)).
+		OnFrameError(func(fe *FrameError) {
+			// 格式化不属于某个元素的帧级错误
+			mu.Lock()
+			frameErrors = append(frameErrors, fe.Error())
+			mu.Unlock()
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- protocol.ServePacket(ctx, pc)
+	}()
+
+	for range 2 {
+		client, err := net.Dial("udp", pc.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		client.SetDeadline(time.Now().Add(time.Second))
+		// 截断的数据报和带多余字节的数据报被跳过, 不影响后续数据报
+		client.Write(frame[:5])
+		client.Write(append(bytes.Clone(frame), 0x00))
+		reply := make([]byte, 64)
+		for range 2 {
+			if _, err := client.Write(frame); err != nil {
+				t.Fatal(err)
+			}
+			n, err := client.Read(reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(reply[:n]) != client.LocalAddr().String() {
+				t.Fatalf("reply routed to wrong session: got %q, want %q", reply[:n], client.LocalAddr())
+			}
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		var serveErr *ServeError
+		if !errors.As(err, &serveErr) || serveErr.Reason != StopCanceled {
+			t.Fatalf("want reason %v, got %v", StopCanceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServePacket was not interrupted by context cancellation")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(frameErrors) != 4 {
+		t.Fatalf("want 4 frame errors, got %q", frameErrors)
+	}
+}
+
+func TestServeStream(t *testing.T) {
+	protocol, err := newTestBuilder().
+		HandleFuncWithParse(FunctionCode(0x03), func(hc *HandlerContext) error {
+			return hc.Session.Send(hc.Code, hc.Frame.Raw())
+		}, func(fh *FunctionHandler) {
+			fh.AddField("ascii", WithAscii(), WithLength(4), WithString())
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不支持读超时的字节流在上下文取消时被关闭
+	serverR, clientW := io.Pipe()
+	clientR, serverW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- protocol.ServeStream(ctx, &pipeStream{serverR, serverW})
+	}()
+
+	frame := []byte{0x68, 0x06, 0x00, 0x03, 0x30, 0x31, 0x32, 0x33, 0x4f, 0xa1}
+	go clientW.Write(frame)
+	echo := make([]byte, len(frame))
+	if _, err := io.ReadFull(clientR, echo); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, frame) {
+		t.Fatalf("unexpected echo: % X", echo)
+	}
+
+	cancel()
+	var serveErr *ServeError
+	select {
+	case err := <-done:
+		if !errors.As(err, &serveErr) || serveErr.Reason != StopCanceled {
+			t.Fatalf("want reason %v, got %v", StopCanceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ServeStream was not interrupted by context cancellation")
+	}
+
+	// 管道文件支持读超时, 取消时不会被关闭
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- protocol.ServeStream(ctx, r)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.As(err, &serveErr) || serveErr.Reason != StopCanceled {
+		t.Fatalf("want reason %v, got %v", StopCanceled, err)
+	}
+	w.Write(frame)
+	r.SetReadDeadline(time.Time{})
+	if _, err := io.ReadFull(r, echo); err != nil {
+		t.Fatalf("pipe was closed on cancellation: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWriteQueueSize 每个优先级发送队列的默认长度
+	defaultWriteQueueSize = 64
+	// maxWriteBatch 一次合并写出的最多帧数
+	maxWriteBatch = 64
+	// flushTimeout 会话结束时写出剩余帧的最长时间
+	flushTimeout = 5 * time.Second
+)
+
+var (
+	// ErrQueueFull 发送队列已满, 策略为 QueueDropNewest 时返回
+	ErrQueueFull = errors.New("发送队列已满")
+	// ErrSessionClosed 会话已结束, 不能再发送
+	ErrSessionClosed = errors.New("会话已结束")
+)
+
+// Priority 发送优先级, 高优先级的帧先于已排队的普通帧写出, 同一优先级内保持发送顺序
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // 普通数据, 如实时数据上报、历史记录等批量数据
+	PriorityHigh                   // 控制命令和应答
+)
+
+// QueueFullPolicy 发送队列满时的策略
+type QueueFullPolicy int
+
+const (
+	QueueBlock      QueueFullPolicy = iota // 阻塞直到队列有空位、上下文取消或会话结束
+	QueueDropNewest                        // 丢弃要发送的帧, 返回 ErrQueueFull
+	QueueDropOldest                        // 丢弃同一优先级中最早排队的帧, 为新帧腾出位置
+)
+
+// outFrame 排队等待写出的帧
+type outFrame struct {
+	code    FunctionCode
+	data    []byte
+	counted bool // 是否计入功能码的发送帧数
+}
+
+// writeQueue 会话的发送队列, 每个优先级一条通道
+type writeQueue struct {
+	lanes    [2]chan outFrame
+	policy   QueueFullPolicy
+	stopping chan struct{} // 会话开始结束, 唤醒阻塞等待队列空位的发送方
+	closing  chan struct{} // 会话结束, 发送协程写出剩余帧后退出
+	done     chan struct{} // 发送协程已退出
+	once     sync.Once
+
+	// sendMu 入队时持有读锁, 关闭时持有写锁设置closed, 保证关闭后不再有帧入队
+	sendMu sync.RWMutex
+	closed bool
+
+	mu  sync.Mutex
+	err error // 写出失败的错误
+}
+
+func newWriteQueue(size int, policy QueueFullPolicy) *writeQueue {
+	if size <= 0 {
+		size = defaultWriteQueueSize
+	}
+	return &writeQueue{
+		lanes:    [2]chan outFrame{make(chan outFrame, size), make(chan outFrame, size)},
+		policy:   policy,
+		stopping: make(chan struct{}),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// failed 返回写出失败的错误
+func (q *writeQueue) failed() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// enqueue 按队列策略将帧放入对应优先级的队列
+func (s *Session) enqueue(ctx context.Context, priority Priority, f outFrame) error {
+	q := s.queue
+	if err := q.failed(); err != nil {
+		return err
+	}
+	q.sendMu.RLock()
+	defer q.sendMu.RUnlock()
+	if q.closed {
+		return ErrSessionClosed
+	}
+	lane := q.lanes[PriorityNormal]
+	if priority >= PriorityHigh {
+		lane = q.lanes[PriorityHigh]
+	}
+	switch q.policy {
+	case QueueDropNewest:
+		select {
+		case lane <- f:
+			return nil
+		default:
+			s.metrics.frameDropped()
+			return ErrQueueFull
+		}
+	case QueueDropOldest:
+		for {
+			select {
+			case lane <- f:
+				return nil
+			default:
+			}
+			select {
+			case old := <-lane:
+				s.metrics.frameDropped()
+				s.Logger().Warn("发送队列已满, 丢弃最早的帧", "code", old.code, "frame", hexBytes(old.data))
+			default:
+			}
+		}
+	default:
+		select {
+		case lane <- f:
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-q.stopping:
+			return ErrSessionClosed
+		case <-q.done:
+			if err := q.failed(); err != nil {
+				return err
+			}
+			return ErrSessionClosed
+		}
+	}
+}
+
+// writeLoop 发送协程, 按优先级取出排队的帧合并写出, 直到会话结束或写出失败
+func (s *Session) writeLoop() {
+	q := s.queue
+	defer close(q.done)
+	batch := make([]outFrame, 0, maxWriteBatch)
+	bufs := make(net.Buffers, 0, maxWriteBatch)
+	for {
+		batch = batch[:0]
+		var first outFrame
+		var ok bool
+		select {
+		case f := <-q.lanes[PriorityHigh]:
+			batch = append(batch, f)
+		case first = <-q.lanes[PriorityNormal]:
+			ok = true
+		case <-q.closing:
+		}
+		// 等待期间排队的高优先级帧排在取到的普通帧之前
+		batch = collect(batch, q.lanes[PriorityHigh])
+		if ok {
+			batch = append(batch, first)
+		}
+		batch = collect(batch, q.lanes[PriorityNormal])
+		if len(batch) == 0 {
+			// 只有会话结束且队列为空时才会取不到帧
+			return
+		}
+		bufs = bufs[:0]
+		for _, f := range batch {
+			bufs = append(bufs, f.data)
+		}
+		if err := s.writeBatch(bufs, batch); err != nil {
+			q.mu.Lock()
+			q.err = err
+			q.mu.Unlock()
+			s.Logger().Warn("发送失败, 关闭连接", "err", err)
+			s.conn.Close()
+			return
+		}
+	}
+}
+
+// collect 不阻塞地取出一个队列中已排队的帧
+func collect(batch []outFrame, lane chan outFrame) []outFrame {
+	for len(batch) < maxWriteBatch {
+		select {
+		case f := <-lane:
+			batch = append(batch, f)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// writeBatch 用一次(支持时为writev)写出多个帧, 并按实际写出的字节记录指标
+func (s *Session) writeBatch(bufs net.Buffers, batch []outFrame) error {
+	n, err := bufs.WriteTo(s.conn)
+	for _, f := range batch {
+		size := int64(len(f.data))
+		if n < size || !f.counted {
+			s.metrics.bytesSent(int(min(n, size)))
+		} else {
+			s.metrics.frameSent(f.code, len(f.data))
+		}
+		n = max(n-size, 0)
+	}
+	return err
+}
+
+// close 结束会话的发送队列, 等待发送协程写出剩余的帧; 超过 flushTimeout 时通过写超时打断写出
+func (s *Session) close() {
+	q := s.queue
+	q.once.Do(func() {
+		// 先唤醒阻塞的发送方释放读锁, 再标记关闭; 之后发送协程能取到所有已入队的帧
+		close(q.stopping)
+		q.sendMu.Lock()
+		q.closed = true
+		q.sendMu.Unlock()
+		close(q.closing)
+	})
+	timer := time.NewTimer(flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-q.done:
+	case <-timer.C:
+		if s.conn.SetWriteDeadline(aLongTimeAgo) == nil {
+			<-q.done
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+* Copyright 2025-2026 longan55 or authors.
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*      https://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package rot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateConn 第一次写入阻塞到gate关闭, 用于在发送协程忙时观察队列
+type gateConn struct {
+	net.Conn
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+func newGateConn() *gateConn {
+	return &gateConn{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (c *gateConn) Write(b []byte) (int, error) {
+	c.once.Do(func() {
+		close(c.started)
+	})
+	<-c.gate
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes = append(c.writes, bytes.Clone(b))
+	return len(b), nil
+}
+
+func (c *gateConn) Close() error                       { return nil }
+func (c *gateConn) RemoteAddr() net.Addr               { return streamAddr("gate") }
+func (c *gateConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func newTestSession(t *testing.T, conn net.Conn, size int, policy QueueFullPolicy) (*Session, *Metrics) {
+	t.Helper()
+	metrics := NewMetrics()
+	protocol, err := newTestBuilder().SetWriteQueue(size, policy).SetMetrics(metrics).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newSession(conn, protocol.(*ProtocolDataUnit)), metrics
+}
+
+func TestSession_SendWholeFrames(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	session, _ := newTestSession(t, server, 4, QueueBlock)
+
+	const senders, frames = 8, 50
+	var wg sync.WaitGroup
+	for i := range senders {
+		frame := bytes.Repeat([]byte{byte(i)}, 16)
+		wg.Go(func() {
+			for range frames {
+				if err := session.Send(0x01, frame); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		session.close()
+		server.Close()
+	}()
+
+	// 每个16字节的块必须来自同一个帧
+	buf := make([]byte, 16)
+	for range senders * frames {
+		if _, err := io.ReadFull(client, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, bytes.Repeat(buf[:1], 16)) {
+			t.Fatalf("interleaved frame: % X", buf)
+		}
+	}
+}
+
+func TestSession_Priority(t *testing.T) {
+	conn := newGateConn()
+	session, _ := newTestSession(t, conn, 8, QueueBlock)
+
+	session.Send(0x01, []byte{0x00})
+	<-conn.started
+	// 发送协程阻塞在第一帧时排队的帧, 控制命令在批量数据之前写出
+	for _, b := range []byte{0x01, 0x02} {
+		session.Send(0x01, []byte{b})
+	}
+	for _, b := range []byte{0xC1, 0xC2} {
+		session.SendContext(context.Background(), PriorityHigh, 0x02, []byte{b})
+	}
+	close(conn.gate)
+	session.close()
+
+	var got []byte
+	for _, w := range conn.writes {
+		got = append(got, w...)
+	}
+	if want := []byte{0x00, 0xC1, 0xC2, 0x01, 0x02}; !bytes.Equal(got, want) {
+		t.Fatalf("want write order % X, got % X", want, got)
+	}
+}
+
+func TestSession_QueueFullPolicy(t *testing.T) {
+	fill := func(policy QueueFullPolicy) (*gateConn, *Session, *Metrics) {
+		conn := newGateConn()
+		session, metrics := newTestSession(t, conn, 2, policy)
+		session.Send(0x01, []byte{0x00})
+		<-conn.started
+		session.Send(0x01, []byte{0x01})
+		session.Send(0x01, []byte{0x02})
+		return conn, session, metrics
+	}
+	written := func(conn *gateConn) []byte {
+		var got []byte
+		for _, w := range conn.writes {
+			got = append(got, w...)
+		}
+		return got
+	}
+
+	conn, session, metrics := fill(QueueDropNewest)
+	if err := session.Send(0x01, []byte{0x03}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("want ErrQueueFull, got %v", err)
+	}
+	close(conn.gate)
+	session.close()
+	if got := written(conn); !bytes.Equal(got, []byte{0x00, 0x01, 0x02}) {
+		t.Fatalf("drop newest: unexpected writes % X", got)
+	}
+	if n := metrics.framesDropped.Load(); n != 1 {
+		t.Fatalf("want 1 dropped frame, got %d", n)
+	}
+
+	conn, session, _ = fill(QueueDropOldest)
+	if err := session.Send(0x01, []byte{0x03}); err != nil {
+		t.Fatal(err)
+	}
+	close(conn.gate)
+	session.close()
+	if got := written(conn); !bytes.Equal(got, []byte{0x00, 0x02, 0x03}) {
+		t.Fatalf("drop oldest: unexpected writes % X", got)
+	}
+
+	conn, session, _ = fill(QueueBlock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := session.SendContext(ctx, PriorityNormal, 0x01, []byte{0x03}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	close(conn.gate)
+	session.close()
+	if err := session.Send(0x01, []byte{0x04}); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("want ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestSession_SendAfterClose(t *testing.T) {
+	for _, policy := range []QueueFullPolicy{QueueBlock, QueueDropNewest, QueueDropOldest} {
+		conn := newGateConn()
+		close(conn.gate)
+		session, _ := newTestSession(t, conn, 4, policy)
+
+		// 与关闭并发的发送要么被写出, 要么返回ErrSessionClosed
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			sent int
+		)
+		for i := range 8 {
+			wg.Go(func() {
+				for range 16 {
+					err := session.Send(0x01, []byte{byte(i)})
+					switch {
+					case err == nil:
+						mu.Lock()
+						sent++
+						mu.Unlock()
+					case errors.Is(err, ErrSessionClosed):
+						return
+					case errors.Is(err, ErrQueueFull):
+					default:
+						t.Errorf("policy %v: unexpected error %v", policy, err)
+						return
+					}
+				}
+			})
+		}
+		session.close()
+		wg.Wait()
+		conn.mu.Lock()
+		written := 0
+		for _, w := range conn.writes {
+			written += len(w)
+		}
+		conn.mu.Unlock()
+		if policy != QueueDropOldest && written != sent {
+			t.Fatalf("policy %v: %d frames accepted, %d written", policy, sent, written)
+		}
+		if err := session.Send(0x01, []byte{0xff}); !errors.Is(err, ErrSessionClosed) {
+			t.Fatalf("policy %v: want ErrSessionClosed after close, got %v", policy, err)
+		}
+	}
+}